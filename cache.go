@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-vcard"
+	"github.com/emersion/go-webdav/carddav"
+)
+
+// offline serves contacts from the local cache without contacting servers.
+var offline bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Read contacts from the local cache without syncing")
+	rootCmd.PersistentFlags().BoolVar(&offline, "no-sync", false, "Alias for --offline")
+}
+
+// multigetBatchSize caps how many cards are requested per addressbook-multiget.
+const multigetBatchSize = 200
+
+// contactCache is the on-disk copy of one CardDAV account's address book.
+// Cards are kept as raw vCard text alongside their ETags so a refresh only
+// needs to download the objects that changed.
+type contactCache struct {
+	path string
+
+	BookPath  string                  `json:"book_path"`
+	SyncToken string                  `json:"sync_token,omitempty"`
+	CTag      string                  `json:"ctag,omitempty"`
+	SyncedAt  time.Time               `json:"synced_at,omitempty"`
+	Objects   map[string]cachedObject `json:"objects"`
+}
+
+type cachedObject struct {
+	ETag string `json:"etag,omitempty"`
+	Card string `json:"card"`
+}
+
+// syncStats summarizes what a refresh changed.
+type syncStats struct {
+	Method  string `json:"method"`
+	Updated int    `json:"updated"`
+	Deleted int    `json:"deleted"`
+}
+
+func cacheDir() string {
+	return filepath.Join(configDir(), "cache")
+}
+
+// cachePath returns the cache file for a service, keyed by endpoint and
+// username so that editing the config never serves another account's cards.
+func cachePath(svc ServiceConfig) string {
+	sum := sha256.Sum256([]byte(svc.Endpoint + "\x00" + svc.Username))
+	return filepath.Join(cacheDir(), "carddav-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadContactCache reads the cache for a service. A missing or corrupt
+// cache yields an empty one; it will be rebuilt on the next sync.
+func loadContactCache(svc ServiceConfig) *contactCache {
+	c := &contactCache{path: cachePath(svc)}
+	if data, err := os.ReadFile(c.path); err == nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: ignoring corrupt contact cache %s: %v\n", c.path, err)
+			c = &contactCache{path: c.path}
+		}
+	}
+	if c.Objects == nil {
+		c.Objects = make(map[string]cachedObject)
+	}
+	return c
+}
+
+func (c *contactCache) save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshaling contact cache: %w", err)
+	}
+	return writeFileAtomic(c.path, data)
+}
+
+// reset drops everything so the next sync downloads the full address book.
+func (c *contactCache) reset() {
+	*c = contactCache{path: c.path, Objects: make(map[string]cachedObject)}
+}
+
+func (c *contactCache) put(path, etag string, card vcard.Card) {
+	var buf bytes.Buffer
+	if err := vcard.NewEncoder(&buf).Encode(card); err != nil {
+		// Unencodable cards can't be cached; forget any stale copy instead.
+		delete(c.Objects, path)
+		return
+	}
+	c.Objects[path] = cachedObject{ETag: etag, Card: buf.String()}
+}
+
+// objects decodes the cached cards, sorted by path for stable output.
+func (c *contactCache) objects() []carddav.AddressObject {
+	paths := make([]string, 0, len(c.Objects))
+	for p := range c.Objects {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	objs := make([]carddav.AddressObject, 0, len(paths))
+	for _, p := range paths {
+		co := c.Objects[p]
+		card, err := vcard.NewDecoder(strings.NewReader(co.Card)).Decode()
+		if err != nil {
+			continue
+		}
+		objs = append(objs, carddav.AddressObject{Path: p, ETag: co.ETag, Card: card})
+	}
+	return objs
+}
+
+// sync brings the cache up to date with the server. It prefers RFC 6578
+// sync-collection, and falls back to comparing the collection CTag and then
+// per-object ETags for servers that don't support it.
+func (c *contactCache) sync(ctx context.Context, client *davClient) (syncStats, error) {
+	if c.BookPath == "" {
+		book, err := findAddressBook(ctx, client)
+		if err != nil {
+			return syncStats{}, err
+		}
+		c.BookPath = book.Path
+	}
+
+	stats, err := c.syncCollection(ctx, client)
+	if err != nil {
+		stats, err = c.syncByETag(ctx, client)
+		if err != nil {
+			return stats, err
+		}
+	}
+	c.SyncedAt = time.Now().UTC()
+	return stats, c.save()
+}
+
+func (c *contactCache) syncCollection(ctx context.Context, client *davClient) (syncStats, error) {
+	stats := syncStats{Method: "sync-collection"}
+	full := c.SyncToken == "" || c.SyncedAt.IsZero()
+	resp, err := client.SyncCollection(ctx, c.BookPath, &carddav.SyncQuery{SyncToken: c.SyncToken})
+	if err != nil && !full {
+		// The server may have expired our token; start over from scratch.
+		full = true
+		resp, err = client.SyncCollection(ctx, c.BookPath, &carddav.SyncQuery{})
+	}
+	if err != nil {
+		c.SyncToken = ""
+		return stats, err
+	}
+
+	seen := make(map[string]bool, len(resp.Updated))
+	var changed []string
+	for _, obj := range resp.Updated {
+		seen[obj.Path] = true
+		if cached, ok := c.Objects[obj.Path]; !ok || obj.ETag == "" || cached.ETag != obj.ETag {
+			changed = append(changed, obj.Path)
+		}
+	}
+	for _, p := range resp.Deleted {
+		if _, ok := c.Objects[p]; ok {
+			delete(c.Objects, p)
+			stats.Deleted++
+		}
+	}
+	if full {
+		stats.Deleted += c.dropUnseen(seen)
+	}
+	if err := c.fetch(ctx, client, changed); err != nil {
+		return stats, err
+	}
+	stats.Updated = len(changed)
+	c.SyncToken = resp.SyncToken
+	return stats, nil
+}
+
+func (c *contactCache) syncByETag(ctx context.Context, client *davClient) (syncStats, error) {
+	ctag, ctagErr := fetchCTag(ctx, client, c.BookPath)
+	if ctagErr == nil && ctag != "" && ctag == c.CTag && !c.SyncedAt.IsZero() {
+		return syncStats{Method: "ctag"}, nil
+	}
+
+	stats := syncStats{Method: "etag"}
+	resps, err := propfind(ctx, client, c.BookPath, "1", "<d:getetag/>")
+	if err != nil {
+		return stats, fmt.Errorf("listing contacts: %w", err)
+	}
+	seen := make(map[string]bool, len(resps))
+	var changed []string
+	for _, r := range resps {
+		p := r.path()
+		if strings.TrimSuffix(p, "/") == strings.TrimSuffix(c.BookPath, "/") {
+			continue
+		}
+		seen[p] = true
+		etag := r.etag()
+		if cached, ok := c.Objects[p]; !ok || etag == "" || cached.ETag != etag {
+			changed = append(changed, p)
+		}
+	}
+	stats.Deleted = c.dropUnseen(seen)
+	if err := c.fetch(ctx, client, changed); err != nil {
+		return stats, err
+	}
+	stats.Updated = len(changed)
+	c.CTag = ctag
+	return stats, nil
+}
+
+// dropUnseen removes cached objects the server no longer lists.
+func (c *contactCache) dropUnseen(seen map[string]bool) int {
+	var n int
+	for p := range c.Objects {
+		if !seen[p] {
+			delete(c.Objects, p)
+			n++
+		}
+	}
+	return n
+}
+
+// fetch downloads the given cards with addressbook-multiget and stores them.
+func (c *contactCache) fetch(ctx context.Context, client *davClient, paths []string) error {
+	for start := 0; start < len(paths); start += multigetBatchSize {
+		end := min(start+multigetBatchSize, len(paths))
+		objs, err := client.MultiGetAddressBook(ctx, c.BookPath, &carddav.AddressBookMultiGet{
+			Paths:       paths[start:end],
+			DataRequest: carddav.AddressDataRequest{AllProp: true},
+		})
+		if err != nil {
+			return fmt.Errorf("fetching contacts: %w", err)
+		}
+		for _, obj := range objs {
+			c.put(obj.Path, obj.ETag, obj.Card)
+		}
+	}
+	return nil
+}
+
+// davResponse is the subset of a PROPFIND multistatus response frm reads.
+type davResponse struct {
+	Href      string `xml:"DAV: href"`
+	Propstats []struct {
+		Prop struct {
+			ETag string `xml:"DAV: getetag"`
+			CTag string `xml:"http://calendarserver.org/ns/ getctag"`
+		} `xml:"DAV: prop"`
+	} `xml:"DAV: propstat"`
+}
+
+func (r davResponse) path() string {
+	if u, err := url.Parse(r.Href); err == nil {
+		return u.Path
+	}
+	return r.Href
+}
+
+func (r davResponse) etag() string {
+	for _, ps := range r.Propstats {
+		if v := ps.Prop.ETag; v != "" {
+			if unq, err := strconv.Unquote(v); err == nil {
+				return unq
+			}
+			return v
+		}
+	}
+	return ""
+}
+
+func (r davResponse) ctag() string {
+	for _, ps := range r.Propstats {
+		if ps.Prop.CTag != "" {
+			return ps.Prop.CTag
+		}
+	}
+	return ""
+}
+
+// propfind issues a raw PROPFIND for the given properties. go-webdav's
+// ReadDir insists on properties some servers omit, and has no getctag.
+func propfind(ctx context.Context, client *davClient, path, depth, props string) ([]davResponse, error) {
+	body := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/"><d:prop>` + props + `</d:prop></d:propfind>`
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", client.resolve(path), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("Depth", depth)
+	resp, err := client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("PROPFIND %s: %s", path, resp.Status)
+	}
+
+	var ms struct {
+		Responses []davResponse `xml:"DAV: response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("decoding PROPFIND response: %w", err)
+	}
+	return ms.Responses, nil
+}
+
+// fetchETag asks for the current ETag of a single object.
+func fetchETag(ctx context.Context, client *davClient, path string) (string, error) {
+	resps, err := propfind(ctx, client, path, "0", "<d:getetag/>")
+	if err != nil {
+		return "", err
+	}
+	for _, r := range resps {
+		if etag := r.etag(); etag != "" {
+			return etag, nil
+		}
+	}
+	return "", nil
+}
+
+// fetchCTag reads the CalendarServer getctag property of a collection.
+// Servers without it return an empty string.
+func fetchCTag(ctx context.Context, client *davClient, path string) (string, error) {
+	resps, err := propfind(ctx, client, path, "0", "<cs:getctag/>")
+	if err != nil {
+		return "", err
+	}
+	for _, r := range resps {
+		if ctag := r.ctag(); ctag != "" {
+			return ctag, nil
+		}
+	}
+	return "", nil
+}
+
+// accountContacts returns one account's contacts from the local cache,
+// refreshing it first unless --offline is set.
+func accountContacts(ctx context.Context, svc ServiceConfig) (*davClient, []carddav.AddressObject, error) {
+	client, err := newCardDAVClient(svc)
+	if err != nil {
+		return nil, nil, err
+	}
+	client.cache = loadContactCache(svc)
+	if offline {
+		if client.cache.SyncedAt.IsZero() {
+			return nil, nil, fmt.Errorf("no cached contacts for %s; run 'frm sync' first", svc.label())
+		}
+	} else if _, err := client.cache.sync(ctx, client); err != nil {
+		return nil, nil, err
+	}
+	return client, client.cache.objects(), nil
+}
@@ -1,28 +1,158 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/emersion/go-vcard"
 	"github.com/emersion/go-webdav"
 	"github.com/emersion/go-webdav/carddav"
 )
 
-func newCardDAVClient(svc ServiceConfig) (*carddav.Client, error) {
+// davClient is a CardDAV client that also keeps the raw HTTP client and
+// endpoint, for the few requests go-webdav doesn't expose (e.g. getctag).
+type davClient struct {
+	*carddav.Client
+	http     webdav.HTTPClient
+	endpoint *url.URL
+	cache    *contactCache
+}
+
+func newCardDAVClient(svc ServiceConfig) (*davClient, error) {
 	endpoint := strings.TrimSuffix(svc.Endpoint, "/")
 	httpClient := webdav.HTTPClientWithBasicAuth(http.DefaultClient, svc.Username, svc.Password)
 	client, err := carddav.NewClient(httpClient, endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to CardDAV: %w", err)
 	}
-	return client, nil
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CardDAV endpoint: %w", err)
+	}
+	return &davClient{Client: client, http: httpClient, endpoint: u}, nil
+}
+
+// resolve turns a server path (as returned in hrefs) into an absolute URL.
+func (c *davClient) resolve(p string) string {
+	u := *c.endpoint
+	u.Path = p
+	return u.String()
+}
+
+// PutAddressObject writes a card and, if the client has a local cache,
+// records the new version there so offline reads see the change.
+func (c *davClient) PutAddressObject(ctx context.Context, path string, card vcard.Card) (*carddav.AddressObject, error) {
+	obj, err := c.Client.PutAddressObject(ctx, path, card)
+	if err != nil {
+		return nil, err
+	}
+	if c.cache != nil {
+		c.cache.put(path, obj.ETag, card)
+		if err := c.cache.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: updating contact cache: %v\n", err)
+		}
+	}
+	return obj, nil
 }
 
-func findAddressBook(ctx context.Context, client *carddav.Client) (*carddav.AddressBook, error) {
+// errPreconditionFailed is returned when an If-Match write loses a race
+// with another client (HTTP 412).
+var errPreconditionFailed = errors.New("contact was modified on the server")
+
+// maxConflictRetries bounds how often updateContact re-fetches and retries.
+const maxConflictRetries = 3
+
+// putIfMatch writes a card only if the server still has the version with the
+// given ETag. go-webdav's PutAddressObject can't send If-Match, so this issues
+// the PUT directly. An empty etag writes unconditionally.
+func (c *davClient) putIfMatch(ctx context.Context, path, etag string, card vcard.Card) (string, error) {
+	var buf bytes.Buffer
+	if err := vcard.NewEncoder(&buf).Encode(card); err != nil {
+		return "", fmt.Errorf("encoding vcard: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.resolve(path), &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", vcard.MIMEType)
+	if etag != "" {
+		req.Header.Set("If-Match", strconv.Quote(etag))
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return "", errPreconditionFailed
+	}
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("PUT %s: %s", path, resp.Status)
+	}
+	newETag := resp.Header.Get("ETag")
+	if unq, err := strconv.Unquote(newETag); err == nil {
+		newETag = unq
+	}
+	return newETag, nil
+}
+
+// updateContact applies change to obj's card and writes it back, guarded by
+// the ETag obj was read with so edits made elsewhere (e.g. on a phone) in the
+// meantime aren't clobbered. On a conflict it re-fetches the card, re-applies
+// only change, and retries. It reports whether a conflict had to be resolved.
+func (c *davClient) updateContact(ctx context.Context, obj *carddav.AddressObject, change func(vcard.Card)) (bool, error) {
+	conflicted := false
+	for attempt := 0; ; attempt++ {
+		change(obj.Card)
+		etag, err := c.putIfMatch(ctx, obj.Path, obj.ETag, obj.Card)
+		if err == nil && etag == "" {
+			// Some servers leave the ETag off PUT responses. Ask for it so
+			// the next edit is still guarded; failing that, the cache keeps
+			// an empty ETag, which the next sync treats as changed.
+			if etag, err = fetchETag(ctx, c, obj.Path); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: fetching ETag for %s: %v\n", obj.Path, err)
+				err = nil
+			}
+		}
+		if err == nil {
+			obj.ETag = etag
+			if c.cache != nil {
+				c.cache.put(obj.Path, etag, obj.Card)
+				if err := c.cache.save(); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: updating contact cache: %v\n", err)
+				}
+			}
+			return conflicted, nil
+		}
+		if !errors.Is(err, errPreconditionFailed) || attempt >= maxConflictRetries {
+			return conflicted, err
+		}
+		conflicted = true
+		latest, err := c.GetAddressObject(ctx, obj.Path)
+		if err != nil {
+			return conflicted, fmt.Errorf("re-fetching after conflict: %w", err)
+		}
+		obj.ETag = latest.ETag
+		obj.Card = latest.Card
+	}
+}
+
+// conflictNote tells the user a write was retried on top of someone else's edit.
+func conflictNote(name string) string {
+	return fmt.Sprintf("Note: %s was changed elsewhere since it was read; re-applied this change to the latest version", name)
+}
+
+func findAddressBook(ctx context.Context, client *davClient) (*carddav.AddressBook, error) {
 	// Try standard CardDAV discovery: principal → home set → address books.
 	principal, err := client.FindCurrentUserPrincipal(ctx)
 	if err == nil {
@@ -46,7 +176,7 @@ func findAddressBook(ctx context.Context, client *carddav.Client) (*carddav.Addr
 	return nil, fmt.Errorf("could not discover address books (tried standard discovery and direct endpoint)")
 }
 
-func queryAllContacts(ctx context.Context, client *carddav.Client, book *carddav.AddressBook) ([]carddav.AddressObject, error) {
+func queryAllContacts(ctx context.Context, client *davClient, book *carddav.AddressBook) ([]carddav.AddressObject, error) {
 	query := &carddav.AddressBookQuery{
 		DataRequest: carddav.AddressDataRequest{
 			Props: []string{
@@ -63,7 +193,7 @@ func queryAllContacts(ctx context.Context, client *carddav.Client, book *carddav
 	return objs, nil
 }
 
-func findContactByName(ctx context.Context, client *carddav.Client, book *carddav.AddressBook, name string) (*carddav.AddressObject, error) {
+func findContactByName(ctx context.Context, client *davClient, book *carddav.AddressBook, name string) (*carddav.AddressObject, error) {
 	objs, err := queryAllContacts(ctx, client, book)
 	if err != nil {
 		return nil, err
@@ -97,35 +227,25 @@ func findContactByName(ctx context.Context, client *carddav.Client, book *cardda
 // If no exact match is found, it tries fuzzy matching: substring first,
 // then edit distance. A single close match (distance <= 2) is auto-selected
 // with a notice on stderr. Multiple candidates produce a suggestion error.
-func findContactMulti(cfg Config, name string) (*carddav.AddressObject, *carddav.Client, error) {
-	ctx := context.Background()
-
+func findContactMulti(cfg Config, name string) (*carddav.AddressObject, *davClient, error) {
+	results, err := allContactsMulti(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Collect all contacts across accounts for fuzzy fallback.
 	type objWithClient struct {
 		obj    carddav.AddressObject
-		client *carddav.Client
+		client *davClient
 	}
 	var allObjs []objWithClient
 
-	for _, svc := range cfg.carddavServices() {
-		client, err := newCardDAVClient(svc)
-		if err != nil {
-			continue
-		}
-		book, err := findAddressBook(ctx, client)
-		if err != nil {
-			continue
-		}
-		objs, err := queryAllContacts(ctx, client, book)
-		if err != nil {
-			continue
-		}
-		for _, obj := range objs {
+	for _, r := range results {
+		for _, obj := range r.objs {
 			if normalizedTokensEqual(contactName(obj), name) {
-				return &obj, client, nil
+				return &obj, r.client, nil
 			}
-			allObjs = append(allObjs, objWithClient{obj: obj, client: client})
+			allObjs = append(allObjs, objWithClient{obj: obj, client: r.client})
 		}
 	}
 
@@ -152,42 +272,33 @@ func findContactMulti(cfg Config, name string) (*carddav.AddressObject, *carddav
 // contactMatch holds a matched contact and its client, for multi-account mutations.
 type contactMatch struct {
 	obj    *carddav.AddressObject
-	client *carddav.Client
+	client *davClient
 }
 
 // findAllContactsMulti searches all accounts for contacts matching a name.
 // Returns all matches across all accounts.
 // Uses the same fuzzy fallback logic as findContactMulti.
 func findAllContactsMulti(cfg Config, name string) ([]contactMatch, error) {
-	ctx := context.Background()
+	results, err := allContactsMulti(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	var matches []contactMatch
 
 	type objWithClient struct {
 		obj    carddav.AddressObject
-		client *carddav.Client
+		client *davClient
 	}
 	var allObjs []objWithClient
 
-	for _, svc := range cfg.carddavServices() {
-		client, err := newCardDAVClient(svc)
-		if err != nil {
-			continue
-		}
-		book, err := findAddressBook(ctx, client)
-		if err != nil {
-			continue
-		}
-		objs, err := queryAllContacts(ctx, client, book)
-		if err != nil {
-			continue
-		}
-		for _, obj := range objs {
+	for _, r := range results {
+		for _, obj := range r.objs {
 			if normalizedTokensEqual(contactName(obj), name) {
 				o := obj
-				matches = append(matches, contactMatch{obj: &o, client: client})
+				matches = append(matches, contactMatch{obj: &o, client: r.client})
 			}
-			allObjs = append(allObjs, objWithClient{obj: obj, client: client})
+			allObjs = append(allObjs, objWithClient{obj: obj, client: r.client})
 		}
 	}
 	if len(matches) > 0 {
@@ -237,28 +348,108 @@ func contactName(obj carddav.AddressObject) string {
 
 // clientAndContacts holds a client and its fetched contacts, used for multi-account iteration.
 type clientAndContacts struct {
-	client *carddav.Client
+	svc    ServiceConfig
+	client *davClient
 	objs   []carddav.AddressObject
 }
 
-// allContactsMulti fetches contacts from all configured accounts.
+// maxParallelAccounts bounds how many accounts are fetched at once.
+const maxParallelAccounts = 4
+
+// accountTimeout bounds how long fetching a single account may take.
+const accountTimeout = 2 * time.Minute
+
+// accountError records a CardDAV account that couldn't be read.
+type accountError struct {
+	Account string `json:"account"`
+	Error   string `json:"error"`
+}
+
+var (
+	// strict makes any unreachable account a hard error.
+	strict bool
+
+	// accountErrors collects accounts skipped during this run. They're
+	// reported as warnings (or an "errors" array with --json) on exit.
+	accountErrors         []accountError
+	accountErrorsReported bool
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "Fail if any account can't be read, instead of warning")
+}
+
+// recordAccountError notes that an account was skipped. Commands that fetch
+// contacts more than once report each account once, with its latest error.
+func recordAccountError(svc ServiceConfig, err error) {
+	e := accountError{Account: svc.label(), Error: err.Error()}
+	for i := range accountErrors {
+		if accountErrors[i].Account == e.Account {
+			accountErrors[i] = e
+			return
+		}
+	}
+	accountErrors = append(accountErrors, e)
+}
+
+// allContactsMulti fetches contacts from all configured accounts concurrently.
+// Accounts that fail are recorded as warnings and skipped, so one broken
+// server doesn't hide the rest. It only returns an error if every account
+// failed, or if any did and --strict is set.
 func allContactsMulti(cfg Config) ([]clientAndContacts, error) {
-	ctx := context.Background()
+	svcs := cfg.carddavServices()
+	type fetched struct {
+		client *davClient
+		objs   []carddav.AddressObject
+		err    error
+	}
+	out := make([]fetched, len(svcs))
+
+	sem := make(chan struct{}, maxParallelAccounts)
+	var wg sync.WaitGroup
+	for i, svc := range svcs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			ctx, cancel := context.WithTimeout(context.Background(), accountTimeout)
+			defer cancel()
+			client, objs, err := accountContacts(ctx, svc)
+			out[i] = fetched{client: client, objs: objs, err: err}
+		}()
+	}
+	wg.Wait()
+
 	var results []clientAndContacts
-	for _, svc := range cfg.carddavServices() {
-		client, err := newCardDAVClient(svc)
-		if err != nil {
-			return nil, err
-		}
-		book, err := findAddressBook(ctx, client)
-		if err != nil {
-			return nil, err
-		}
-		objs, err := queryAllContacts(ctx, client, book)
-		if err != nil {
-			return nil, err
+	var errs []error
+	for i, f := range out {
+		if f.err != nil {
+			recordAccountError(svcs[i], f.err)
+			errs = append(errs, fmt.Errorf("%s: %w", svcs[i].label(), f.err))
+			continue
 		}
-		results = append(results, clientAndContacts{client: client, objs: objs})
+		results = append(results, clientAndContacts{svc: svcs[i], client: f.client, objs: f.objs})
+	}
+	if len(errs) > 0 && (strict || len(results) == 0) {
+		accountErrorsReported = true
+		return nil, errors.Join(errs...)
 	}
 	return results, nil
 }
+
+// reportAccountErrors prints accounts skipped during this run that weren't
+// already included in the command's JSON output.
+func reportAccountErrors(jsonMode bool) {
+	if len(accountErrors) == 0 || accountErrorsReported {
+		return
+	}
+	if jsonMode {
+		data, _ := json.MarshalIndent(map[string]any{"errors": accountErrors}, "", "  ")
+		fmt.Fprintln(os.Stderr, string(data))
+		return
+	}
+	for _, e := range accountErrors {
+		fmt.Fprintf(os.Stderr, "warning: skipped %s: %s\n", e.Account, e.Error)
+	}
+}
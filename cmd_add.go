@@ -18,6 +18,27 @@ func newUUID() string {
 	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
 }
 
+// newContactCard builds a minimal vCard for a new contact, with FN and an N
+// field split from the name.
+func newContactCard(name string) vcard.Card {
+	card := vcard.Card{
+		"VERSION":                []*vcard.Field{{Value: "3.0"}},
+		vcard.FieldFormattedName: []*vcard.Field{{Value: name}},
+	}
+
+	// Split name into given/family for the N field
+	parts := strings.SplitN(name, " ", 2)
+	given := parts[0]
+	family := ""
+	if len(parts) > 1 {
+		family = parts[1]
+	}
+	card[vcard.FieldName] = []*vcard.Field{{
+		Value: family + ";" + given + ";;;",
+	}}
+	return card
+}
+
 func init() {
 	cmd := &cobra.Command{
 		Use:   "add <name>",
@@ -40,6 +61,7 @@ func init() {
 			if err != nil {
 				return err
 			}
+			client.cache = loadContactCache(svcs[0])
 
 			ctx := context.Background()
 			book, err := findAddressBook(ctx, client)
@@ -47,21 +69,7 @@ func init() {
 				return err
 			}
 
-			card := vcard.Card{
-				"VERSION":                []*vcard.Field{{Value: "3.0"}},
-				vcard.FieldFormattedName: []*vcard.Field{{Value: name}},
-			}
-
-			// Split name into given/family for the N field
-			parts := strings.SplitN(name, " ", 2)
-			given := parts[0]
-			family := ""
-			if len(parts) > 1 {
-				family = parts[1]
-			}
-			card[vcard.FieldName] = []*vcard.Field{{
-				Value: family + ";" + given + ";;;",
-			}}
+			card := newContactCard(name)
 
 			email, _ := cmd.Flags().GetString("email")
 			if email != "" {
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -10,23 +12,119 @@ import (
 )
 
 type overdueContact struct {
-	Name      string `json:"name"`
-	Frequency string `json:"frequency"`
-	LastSeen  string `json:"last_seen,omitempty"`
-	Ago       string `json:"ago,omitempty"`
-	Email     string `json:"email,omitempty"`
-	Phone     string `json:"phone,omitempty"`
-	Org       string `json:"org,omitempty"`
-	Group     string `json:"group,omitempty"`
-	LastNote  string `json:"last_note,omitempty"`
+	Name            string `json:"name"`
+	Frequency       string `json:"frequency"`
+	Schedule        string `json:"schedule,omitempty"` // RRULE, for fixed-schedule contacts
+	FromGroup       bool   `json:"frequency_from_group,omitempty"`
+	LastSeen        string `json:"last_seen,omitempty"`
+	Ago             string `json:"ago,omitempty"`
+	Email           string `json:"email,omitempty"`
+	Phone           string `json:"phone,omitempty"`
+	Org             string `json:"org,omitempty"`
+	Group           string `json:"group,omitempty"`
+	LastNote        string `json:"last_note,omitempty"`
+	LastVia         string `json:"last_via,omitempty"`
+	LastDirection   string `json:"last_direction,omitempty"`
+	LastDurationMin int    `json:"last_duration_min,omitempty"`
+	LastLocation    string `json:"last_location,omitempty"`
+	Due             string `json:"due"`
+	DueInDays       int    `json:"due_in_days"` // negative when overdue
+	DueFrom         string `json:"due_from"`    // when a window like 3w-5w opens; due otherwise
+	DueBy           string `json:"due_by"`      // overdue after this; same as due
+	DueSoon         bool   `json:"due_soon,omitempty"`
+	SnoozedUntil    string `json:"snoozed_until,omitempty"`
+	GroupSnoozed    bool   `json:"group_snoozed,omitempty"` // snoozed_until is the group's snooze
+}
+
+// checkSorts are the orders check --sort accepts.
+var checkSorts = []string{"overdue", "name", "frequency", "priority"}
+
+// dueContact pairs a contact check lists with its due date, for sorting.
+type dueContact struct {
+	contact overdueContact
+	due     contactDue
+	weight  float64 // the group's weight from config
+}
+
+// sortDue orders contacts for check. "overdue" puts never-contacted contacts
+// first, then the longest overdue; "frequency" the most frequent first;
+// "priority" the highest lateness times group weight, as 'frm next' scores
+// them. Ties go to the earlier due date, then the name.
+func sortDue(list []dueContact, by string, now time.Time) {
+	sort.SliceStable(list, func(i, j int) bool {
+		x, y := list[i], list[j]
+		switch by {
+		case "name":
+			return x.contact.Name < y.contact.Name
+		case "overdue":
+			if x.due.Contacted != y.due.Contacted {
+				return !x.due.Contacted
+			}
+		case "frequency":
+			if x.due.Interval != y.due.Interval {
+				return x.due.Interval < y.due.Interval
+			}
+		case "priority":
+			px, py := x.due.lateness(now)*x.weight, y.due.lateness(now)*y.weight
+			if px != py {
+				return px > py
+			}
+		}
+		if !x.due.Due.Equal(y.due.Due) {
+			return x.due.Due.Before(y.due.Due)
+		}
+		return x.contact.Name < y.contact.Name
+	})
 }
 
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
+	cmd := &cobra.Command{
 		Use:     "check",
 		Aliases: []string{"status"},
 		Short:   "Show overdue contacts",
+		Long: `Show tracked contacts that are overdue, followed by birthdays and
+anniversaries coming up within --upcoming (see 'frm upcoming').
+
+Contacts tracked with a window such as --every 3w-5w are listed as due soon
+once its start has passed, and overdue only after its end.
+
+--within also shows contacts who fall due in that time, and --group limits
+the list to one group. Snoozed contacts are left out unless --include-snoozed
+is given. --sort orders the list: overdue (never contacted first, then the
+longest overdue; the default), name, frequency (most frequent first), or
+priority (how overdue, scaled by the group's weight, as in 'frm next').
+
+Groups configured in config.json (see 'frm group config') apply to their
+members: a group's default frequency tracks members without one of their
+own, and a group snooze ('frm group snooze') hides them like their own.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			sortBy, _ := cmd.Flags().GetString("sort")
+			if !slices.Contains(checkSorts, sortBy) {
+				return fmt.Errorf("invalid --sort %q: use %s", sortBy, strings.Join(checkSorts, ", "))
+			}
+			var dueWithin time.Duration
+			withinStr, _ := cmd.Flags().GetString("within")
+			if withinStr != "" {
+				d, err := parseDuration(withinStr)
+				if err != nil {
+					return err
+				}
+				dueWithin = d
+			}
+			groupFilter, _ := cmd.Flags().GetString("group")
+			includeSnoozed, _ := cmd.Flags().GetBool("include-snoozed")
+			limit, _ := cmd.Flags().GetInt("limit")
+
+			var within time.Duration
+			upcomingStr, _ := cmd.Flags().GetString("upcoming")
+			if upcomingStr != "" {
+				d, err := parseDuration(upcomingStr)
+				if err != nil {
+					return err
+				}
+				within = d
+			}
+
 			cfg, err := loadConfig()
 			if err != nil {
 				return err
@@ -62,41 +160,48 @@ func init() {
 			}
 
 			now := time.Now()
-			var overdue []overdueContact
+			var due []dueContact
 
 			for _, r := range results {
 				for _, obj := range r.objs {
-					if isIgnored(obj.Card) || isSnoozed(obj.Card) {
+					if isIgnored(obj.Card) {
 						continue
 					}
-					freq := getFrequency(obj.Card)
-					if freq == "" {
+					d, ok := dueFor(cfg, obj, lastContact, now)
+					if !ok {
 						continue
 					}
-					dur, err := parseDuration(freq)
-					if err != nil {
+					if d.snoozed() && !includeSnoozed {
 						continue
 					}
-					name := contactName(obj)
-					// Prefer path-based lookup (survives renames), fall back to name
-					last, ok := lastContact[obj.Path]
-					if !ok {
-						last, ok = lastContact[name]
+					if groupFilter != "" && !strings.EqualFold(getGroup(obj.Card), groupFilter) {
+						continue
 					}
-
-					isOverdue := !ok || now.Sub(last) > dur
-
-					if !isOverdue {
+					if !d.overdue(now) && d.DueFrom.After(now.Add(dueWithin)) {
 						continue
 					}
+					name := contactName(obj)
 
 					oc := overdueContact{
 						Name:      name,
-						Frequency: freq,
+						Frequency: d.Frequency,
+						Due:       d.Due.Format("2006-01-02"),
+						DueInDays: d.dueInDays(now),
+						DueFrom:   d.DueFrom.Format("2006-01-02"),
+						DueBy:     d.Due.Format("2006-01-02"),
+						DueSoon:   d.dueSoon(now),
+						FromGroup: d.FromGroup,
+					}
+					if d.Schedule != nil {
+						oc.Schedule = d.Schedule.Rule
+					}
+					if d.Contacted {
+						oc.LastSeen = d.Last.Format("2006-01-02")
+						oc.Ago = formatAgo(now.Sub(d.Last))
 					}
-					if ok {
-						oc.LastSeen = last.Format("2006-01-02")
-						oc.Ago = formatAgo(now.Sub(last))
+					if d.snoozed() {
+						oc.SnoozedUntil = d.SnoozedUntil.Format("2006-01-02")
+						oc.GroupSnoozed = d.GroupSnoozed
 					}
 
 					// Enrich with contact details for JSON consumers
@@ -113,41 +218,124 @@ func init() {
 						if group := getGroup(obj.Card); group != "" {
 							oc.Group = group
 						}
-						// Find last note from log entries
+						// Find last note and channel from log entries
 						le := lastEntry[obj.Path]
 						if le == nil {
 							le = lastEntry[name]
 						}
-						if le != nil && le.Note != "" {
+						if le != nil {
 							oc.LastNote = le.Note
+							oc.LastVia = le.Via
+							oc.LastDirection = le.Direction
+							oc.LastDurationMin = le.DurationMin
+							oc.LastLocation = le.Location
 						}
 					}
 
-					overdue = append(overdue, oc)
+					due = append(due, dueContact{contact: oc, due: d, weight: cfg.groupWeight(getGroup(obj.Card))})
 				}
 			}
 
+			sortDue(due, sortBy, now)
+			if limit >= 0 && limit < len(due) {
+				due = due[:limit]
+			}
+
 			if jsonFlag {
-				return printJSON(cmd, overdue)
+				overdue := make([]overdueContact, len(due))
+				for i, dc := range due {
+					overdue[i] = dc.contact
+				}
+				result := map[string]interface{}{"contacts": overdue}
+				if upcomingStr != "" {
+					result["upcoming"] = upcomingEvents(results, within)
+				}
+				return printJSON(cmd, result)
+			}
+
+			line := func(dc dueContact) string {
+				o := dc.contact
+				details := []string{dc.due.cadence()}
+				if o.Ago == "" {
+					details = append(details, "never contacted")
+				} else {
+					details = append(details, "last contact "+o.Ago+" ago")
+				}
+				switch {
+				case dc.due.overdue(now):
+				case o.DueSoon:
+					details = append(details, "due by "+o.DueBy)
+				default:
+					details = append(details, dueIn(daysFrom(now, dc.due.DueFrom)))
+				}
+				switch {
+				case o.GroupSnoozed:
+					details = append(details, "group snoozed until "+o.SnoozedUntil)
+				case o.SnoozedUntil != "":
+					details = append(details, "snoozed until "+o.SnoozedUntil)
+				}
+				return fmt.Sprintf("  %s (%s)", o.Name, strings.Join(details, ", "))
 			}
 
-			if len(overdue) == 0 {
-				fmt.Println("All caught up! No overdue contacts.")
+			if withinStr != "" {
+				if len(due) == 0 {
+					fmt.Printf("All caught up! Nobody is due within %s.\n", withinStr)
+				} else {
+					fmt.Printf("Due within %s:\n", withinStr)
+					for _, dc := range due {
+						fmt.Println(line(dc))
+					}
+				}
 			} else {
-				fmt.Println("Overdue contacts:")
-				var lines []string
-				for _, o := range overdue {
-					if o.Ago == "" {
-						lines = append(lines, fmt.Sprintf("  %s (every %s, never contacted)", o.Name, o.Frequency))
+				// Contacts whose window (e.g. 3w-5w) has opened are listed
+				// apart from the overdue ones.
+				var overdue, soon []string
+				for _, dc := range due {
+					if dc.contact.DueSoon {
+						soon = append(soon, line(dc))
 					} else {
-						lines = append(lines, fmt.Sprintf("  %s (every %s, last contact %s ago)", o.Name, o.Frequency, o.Ago))
+						overdue = append(overdue, line(dc))
 					}
 				}
-				fmt.Println(strings.Join(lines, "\n"))
+				if len(overdue) == 0 {
+					fmt.Println("All caught up! No overdue contacts.")
+				} else {
+					fmt.Println("Overdue contacts:")
+					fmt.Println(strings.Join(overdue, "\n"))
+				}
+				if len(soon) > 0 {
+					fmt.Println("\nDue soon:")
+					fmt.Println(strings.Join(soon, "\n"))
+				}
+			}
+
+			if upcomingStr != "" {
+				if events := upcomingEvents(results, within); len(events) > 0 {
+					fmt.Printf("\nComing up in the next %s:\n", upcomingStr)
+					printUpcoming(events)
+				}
 			}
 			return nil
 		},
-	})
+	}
+	cmd.Flags().String("group", "", "Only show contacts in this group")
+	cmd.Flags().String("sort", "overdue", "Sort by overdue, name, frequency, or priority")
+	cmd.Flags().Int("limit", -1, "Max contacts to show (-1 for unlimited)")
+	cmd.Flags().Bool("include-snoozed", false, "Include snoozed contacts")
+	cmd.Flags().String("within", "", "Also show contacts due within this long (e.g. 7d)")
+	cmd.Flags().String("upcoming", "7d", "Also show birthdays and anniversaries this far ahead (\"\" to hide)")
+	rootCmd.AddCommand(cmd)
+}
+
+// dueIn describes a due date days from today, for a contact not yet overdue.
+func dueIn(days int) string {
+	switch days {
+	case 0:
+		return "due today"
+	case 1:
+		return "due tomorrow"
+	}
+	return fmt.Sprintf("due in %d days", days)
 }
 
 func formatAgo(d time.Duration) string {
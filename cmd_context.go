@@ -9,11 +9,17 @@ import (
 )
 
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
+	cmd := &cobra.Command{
 		Use:     "context <name>",
 		Aliases: []string{"show", "detail"},
 		Short:   "Pre-meeting prep: show contact summary",
-		Args:    cobra.ExactArgs(1),
+		Long: `Show what you know about a contact before you meet: their frequency and
+due date, birthday, the last interaction, and context from configured email
+and calendar services.
+
+Email and calendar context is cached for an hour per contact (see cache_ttl
+in the README); pass --refresh to look it up again.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := loadConfig()
 			if err != nil {
@@ -28,21 +34,37 @@ func init() {
 			freq := getFrequency(obj.Card)
 			ignored := isIgnored(obj.Card)
 			group := getGroup(obj.Card)
+			events := cardEvents(obj.Card, name, dateOnly(time.Now()))
 
 			entries, err := readLog()
 			if err != nil {
 				return err
 			}
 
+			// The latest interaction, which isn't always the last line:
+			// sync-mail and sync-meetings log past interactions.
 			nameLower := strings.ToLower(name)
 			var lastEntry *LogEntry
-			for i := len(entries) - 1; i >= 0; i-- {
-				if strings.ToLower(entries[i].Contact) == nameLower || entries[i].Path == obj.Path {
+			for i := range entries {
+				if strings.ToLower(entries[i].Contact) != nameLower && entries[i].Path != obj.Path {
+					continue
+				}
+				if lastEntry == nil || !entries[i].Time.Before(lastEntry.Time) {
 					lastEntry = &entries[i]
-					break
 				}
 			}
 
+			now := time.Now()
+			lastContact := make(map[string]time.Time)
+			if lastEntry != nil {
+				lastContact[obj.Path] = lastEntry.Time
+			}
+			due, tracked := dueFor(cfg, *obj, lastContact, now)
+			daysUntilDue := due.dueInDays(now)
+			if tracked && due.FromGroup {
+				freq = due.Frequency
+			}
+
 			jsonFlag, _ := cmd.Flags().GetBool("json")
 			if jsonFlag {
 				result := map[string]any{
@@ -52,30 +74,50 @@ func init() {
 				if freq != "" {
 					result["frequency"] = freq
 				}
+				if tracked && due.FromGroup {
+					result["frequency_from_group"] = true
+				}
+				if tracked && due.Schedule != nil {
+					result["schedule"] = due.Schedule.Rule
+				}
 				if group != "" {
 					result["group"] = group
 				}
+				for _, e := range events {
+					result[e.Kind] = e.Original
+				}
+				if len(events) > 0 {
+					result["upcoming"] = events
+				}
 				if lastEntry != nil {
 					result["last_contact"] = lastEntry.Time.Format(time.RFC3339)
 					if lastEntry.Note != "" {
 						result["last_note"] = lastEntry.Note
 					}
+					if lastEntry.Via != "" {
+						result["last_via"] = lastEntry.Via
+					}
+					if lastEntry.Direction != "" {
+						result["last_direction"] = lastEntry.Direction
+					}
+					if lastEntry.DurationMin > 0 {
+						result["last_duration_min"] = lastEntry.DurationMin
+					}
+					if lastEntry.Location != "" {
+						result["last_location"] = lastEntry.Location
+					}
 					result["days_since"] = int(time.Since(lastEntry.Time).Hours() / 24)
 				}
-				if freq != "" {
-					dur, err := parseDuration(freq)
-					if err == nil {
-						if lastEntry != nil {
-							daysUntil := int((dur - time.Since(lastEntry.Time)).Hours() / 24)
-							result["days_until_due"] = daysUntil
-						} else {
-							result["days_until_due"] = 0
-						}
-					}
+				if tracked {
+					result["days_until_due"] = daysUntilDue
+					result["due_from"] = due.DueFrom.Format("2006-01-02")
+					result["due_by"] = due.Due.Format("2006-01-02")
 				}
 				providers := initProviders(cfg)
-				if lines := collectContext(providers, obj.Card); len(lines) > 0 {
-					result["providers"] = lines
+				defer providers.close()
+				providers.refresh, _ = cmd.Flags().GetBool("refresh")
+				if groups := collectContext(providers, *obj); len(groups) > 0 {
+					result["providers"] = groups
 				}
 				return printJSON(cmd, result)
 			}
@@ -87,38 +129,58 @@ func init() {
 			if group != "" {
 				fmt.Printf("Group:     %s\n", group)
 			}
-			if freq != "" {
+			switch {
+			case tracked && due.Schedule != nil:
+				fmt.Printf("Schedule:  %s\n", due.cadence())
+			case tracked && due.FromGroup:
+				fmt.Printf("Frequency: every %s (%s default)\n", freq, group)
+			case freq != "":
 				fmt.Printf("Frequency: every %s\n", freq)
-			} else {
+			default:
 				fmt.Println("Frequency: not tracked")
 			}
+			for _, e := range events {
+				label := strings.ToUpper(e.Kind[:1]) + e.Kind[1:] + ":"
+				detail := e.when()
+				switch {
+				case e.Years > 0 && e.Kind == "birthday":
+					detail = fmt.Sprintf("turning %d %s", e.Years, detail)
+				case e.Years > 0:
+					detail = fmt.Sprintf("%d years %s", e.Years, detail)
+				}
+				fmt.Printf("%-10s %s (%s)\n", label, e.Original, detail)
+			}
 
 			if lastEntry != nil {
 				daysSince := int(time.Since(lastEntry.Time).Hours() / 24)
 				fmt.Printf("Last seen: %s (%d days ago)\n", lastEntry.Time.Format("2006-01-02"), daysSince)
+				if d := lastEntry.details(); d != "" {
+					fmt.Printf("Last via:  %s\n", d)
+				}
 				if lastEntry.Note != "" {
 					fmt.Printf("Last note: %s\n", lastEntry.Note)
 				}
-				if freq != "" {
-					dur, err := parseDuration(freq)
-					if err == nil {
-						daysUntil := int((dur - time.Since(lastEntry.Time)).Hours() / 24)
-						if daysUntil < 0 {
-							fmt.Printf("Status:    overdue by %d days\n", -daysUntil)
-						} else {
-							fmt.Printf("Due in:    %d days\n", daysUntil)
-						}
-					}
-				}
 			} else {
 				fmt.Println("Last seen: never")
-				if freq != "" {
-					fmt.Println("Status:    overdue (never contacted)")
-				}
+			}
+			switch {
+			case !tracked:
+			case !due.Contacted && due.overdue(now):
+				fmt.Println("Status:    overdue (never contacted)")
+			case daysUntilDue < 0:
+				fmt.Printf("Status:    overdue by %d days\n", -daysUntilDue)
+			case due.overdue(now):
+				fmt.Println("Status:    due today")
+			case due.dueSoon(now):
+				fmt.Printf("Status:    due soon (overdue after %s)\n", due.Due.Format("2006-01-02"))
+			default:
+				fmt.Printf("Due in:    %d days\n", daysUntilDue)
 			}
 
 			providers := initProviders(cfg)
-			if lines := collectContext(providers, obj.Card); len(lines) > 0 {
+			defer providers.close()
+			providers.refresh, _ = cmd.Flags().GetBool("refresh")
+			if lines := contextLines(collectContext(providers, *obj)); len(lines) > 0 {
 				fmt.Println()
 				for _, line := range lines {
 					fmt.Println(line)
@@ -126,5 +188,7 @@ func init() {
 			}
 			return nil
 		},
-	})
+	}
+	cmd.Flags().Bool("refresh", false, "Look up email and calendar context again instead of using the cache")
+	rootCmd.AddCommand(cmd)
 }
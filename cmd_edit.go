@@ -29,57 +29,99 @@ func init() {
 
 			displayName := contactName(*obj)
 
-			// Collect changes from explicitly provided flags.
+			// Collect changes from explicitly provided flags. They're applied
+			// via a function so they can be replayed if the card changed on
+			// the server since we read it.
 			var changes []string
+			var edits []func(vcard.Card)
 
 			email, _ := cmd.Flags().GetString("email")
 			if cmd.Flags().Changed("email") {
-				obj.Card[vcard.FieldEmail] = []*vcard.Field{{Value: email}}
+				edits = append(edits, func(card vcard.Card) {
+					card[vcard.FieldEmail] = []*vcard.Field{{Value: email}}
+				})
 				changes = append(changes, fmt.Sprintf("email=%s", email))
 			}
 
 			phone, _ := cmd.Flags().GetString("phone")
 			if cmd.Flags().Changed("phone") {
-				obj.Card[vcard.FieldTelephone] = []*vcard.Field{{Value: phone}}
+				edits = append(edits, func(card vcard.Card) {
+					card[vcard.FieldTelephone] = []*vcard.Field{{Value: phone}}
+				})
 				changes = append(changes, fmt.Sprintf("phone=%s", phone))
 			}
 
 			org, _ := cmd.Flags().GetString("org")
 			if cmd.Flags().Changed("org") {
-				obj.Card[vcard.FieldOrganization] = []*vcard.Field{{Value: org}}
+				edits = append(edits, func(card vcard.Card) {
+					card[vcard.FieldOrganization] = []*vcard.Field{{Value: org}}
+				})
 				changes = append(changes, fmt.Sprintf("org=%s", org))
 			}
 
 			urls, _ := cmd.Flags().GetStringSlice("url")
 			if cmd.Flags().Changed("url") {
-				// Collect existing URLs for dedup.
-				seen := make(map[string]bool)
-				var merged []*vcard.Field
-				for _, f := range obj.Card[vcard.FieldURL] {
-					if f.Value != "" && !seen[f.Value] {
-						seen[f.Value] = true
-						merged = append(merged, f)
+				edits = append(edits, func(card vcard.Card) {
+					// Collect existing URLs for dedup.
+					seen := make(map[string]bool)
+					var merged []*vcard.Field
+					for _, f := range card[vcard.FieldURL] {
+						if f.Value != "" && !seen[f.Value] {
+							seen[f.Value] = true
+							merged = append(merged, f)
+						}
 					}
-				}
-				for _, u := range urls {
-					if u != "" && !seen[u] {
-						seen[u] = true
-						merged = append(merged, &vcard.Field{Value: u})
+					for _, u := range urls {
+						if u != "" && !seen[u] {
+							seen[u] = true
+							merged = append(merged, &vcard.Field{Value: u})
+						}
 					}
-				}
-				obj.Card[vcard.FieldURL] = merged
+					card[vcard.FieldURL] = merged
+				})
 				changes = append(changes, fmt.Sprintf("url=%s", strings.Join(urls, ",")))
 			}
 
+			for _, df := range []struct {
+				flag   string
+				set    func(vcard.Card, cardDate)
+				remove func(vcard.Card)
+			}{
+				{"birthday", setBirthday, removeBirthday},
+				{"anniversary", setAnniversary, removeAnniversary},
+			} {
+				if !cmd.Flags().Changed(df.flag) {
+					continue
+				}
+				v, _ := cmd.Flags().GetString(df.flag)
+				if v == "" {
+					edits = append(edits, df.remove)
+					changes = append(changes, df.flag+"=")
+					continue
+				}
+				d, err := parseDateFlag(v)
+				if err != nil {
+					return err
+				}
+				edits = append(edits, func(card vcard.Card) { df.set(card, d) })
+				changes = append(changes, fmt.Sprintf("%s=%s", df.flag, d))
+			}
+
 			if len(changes) == 0 {
-				return fmt.Errorf("no fields to update (use --email, --phone, --org, or --url)")
+				return fmt.Errorf("no fields to update (use --email, --phone, --org, --url, --birthday, or --anniversary)")
 			}
 
 			dryRun := isDryRun(cmd)
 
+			var conflicted bool
 			if !dryRun {
 				ctx := context.Background()
-				if _, err := client.PutAddressObject(ctx, obj.Path, obj.Card); err != nil {
+				conflicted, err = client.updateContact(ctx, obj, func(card vcard.Card) {
+					for _, edit := range edits {
+						edit(card)
+					}
+				})
+				if err != nil {
 					return fmt.Errorf("updating contact: %w", err)
 				}
 			}
@@ -90,6 +132,9 @@ func init() {
 					"name":    displayName,
 					"changes": changes,
 				}
+				if conflicted {
+					out["conflicts"] = 1
+				}
 				if dryRun {
 					out["dry_run"] = true
 				}
@@ -101,6 +146,9 @@ func init() {
 			} else {
 				fmt.Printf("Updated %s: %s\n", displayName, strings.Join(changes, ", "))
 			}
+			if conflicted {
+				fmt.Println(conflictNote(displayName))
+			}
 			return nil
 		},
 	}
@@ -108,5 +156,21 @@ func init() {
 	cmd.Flags().String("phone", "", "phone number")
 	cmd.Flags().String("org", "", "organization")
 	cmd.Flags().StringSlice("url", nil, "website or social URL (repeatable, deduped)")
+	cmd.Flags().String("birthday", "", "birthday (YYYY-MM-DD, or MM-DD without a year; empty to clear)")
+	cmd.Flags().String("anniversary", "", "anniversary (YYYY-MM-DD, or MM-DD without a year; empty to clear)")
 	rootCmd.AddCommand(cmd)
 }
+
+// parseDateFlag parses a --birthday or --anniversary value: YYYY-MM-DD, or
+// MM-DD / --MM-DD when the year isn't known.
+func parseDateFlag(s string) (cardDate, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 5 && s[2] == '-' {
+		s = "--" + s
+	}
+	d, ok := parseCardDate(s)
+	if !ok {
+		return cardDate{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD, or MM-DD without a year", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-vcard"
+	"github.com/emersion/go-webdav/carddav"
+	"github.com/spf13/cobra"
+)
+
+// exportVersion is bumped when the archive format changes incompatibly.
+const exportVersion = 1
+
+// frmMeta is the X-FRM-* metadata frm keeps on a card.
+type frmMeta struct {
+	Frequency     string `json:"frequency,omitempty"`
+	Schedule      string `json:"schedule,omitempty"`
+	ScheduleStart string `json:"schedule_start,omitempty"` // YYYY-MM-DD
+	Ignored       bool   `json:"ignored,omitempty"`
+	Group         string `json:"group,omitempty"`
+	SnoozeUntil   string `json:"snooze_until,omitempty"`
+}
+
+func readMeta(card vcard.Card) frmMeta {
+	m := frmMeta{
+		Frequency:   getFrequency(card),
+		Ignored:     isIgnored(card),
+		Group:       getGroup(card),
+		SnoozeUntil: card.PreferredValue(fieldSnoozeUntil),
+	}
+	if rule, start := getSchedule(card); rule != "" {
+		m.Schedule = rule
+		if !start.IsZero() {
+			m.ScheduleStart = start.Format("2006-01-02")
+		}
+	}
+	return m
+}
+
+// apply makes card's metadata match m, removing fields m doesn't set.
+func (m frmMeta) apply(card vcard.Card) {
+	if m.Frequency != "" {
+		setFrequency(card, m.Frequency)
+	} else {
+		removeFrequency(card)
+	}
+	if m.Schedule != "" {
+		start, _ := time.Parse("2006-01-02", m.ScheduleStart)
+		setSchedule(card, m.Schedule, start)
+	} else {
+		removeSchedule(card)
+	}
+	if m.Ignored {
+		setIgnored(card)
+	} else {
+		removeIgnored(card)
+	}
+	if m.Group != "" {
+		setGroup(card, m.Group)
+	} else {
+		removeGroup(card)
+	}
+	if m.SnoozeUntil != "" {
+		card[fieldSnoozeUntil] = []*vcard.Field{{Value: m.SnoozeUntil}}
+	} else {
+		removeSnoozeUntil(card)
+	}
+}
+
+// metaChange is one field restore would change.
+type metaChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// diff lists the fields that differ between m and want.
+func (m frmMeta) diff(want frmMeta) []metaChange {
+	var changes []metaChange
+	add := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, metaChange{Field: field, From: from, To: to})
+		}
+	}
+	add("frequency", m.Frequency, want.Frequency)
+	add("schedule", m.Schedule, want.Schedule)
+	add("schedule_start", m.ScheduleStart, want.ScheduleStart)
+	add("ignored", fmt.Sprint(m.Ignored), fmt.Sprint(want.Ignored))
+	add("group", m.Group, want.Group)
+	add("snooze_until", m.SnoozeUntil, want.SnoozeUntil)
+	return changes
+}
+
+// exportContact is one card in an export archive. The full vCard is kept so
+// the archive is a complete backup; restore only reads the metadata.
+type exportContact struct {
+	Account string   `json:"account"`
+	Path    string   `json:"path"`
+	UID     string   `json:"uid,omitempty"`
+	Name    string   `json:"name"`
+	Emails  []string `json:"emails,omitempty"`
+	frmMeta
+	VCard string `json:"vcard"`
+}
+
+// exportArchive is the document written by 'frm export'.
+type exportArchive struct {
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Contacts   []exportContact `json:"contacts"`
+	Log        []LogEntry      `json:"log"`
+}
+
+func cardEmails(card vcard.Card) []string {
+	var emails []string
+	for _, f := range card[vcard.FieldEmail] {
+		if e := strings.ToLower(strings.TrimSpace(f.Value)); e != "" {
+			emails = append(emails, e)
+		}
+	}
+	return emails
+}
+
+// logEntryKey identifies a log entry for de-duplication when merging.
+func logEntryKey(e LogEntry) string {
+	return strings.ToLower(e.Contact) + "\x00" + e.Time.UTC().Format(time.RFC3339Nano) + "\x00" + e.Note
+}
+
+// restoreTarget is an existing contact restore can write to.
+type restoreTarget struct {
+	account string
+	obj     *carddav.AddressObject
+	client  *davClient
+}
+
+// matchExported finds the current card for an exported one: by account and
+// path, then UID, then email, then name.
+func matchExported(ec exportContact, targets []restoreTarget) *restoreTarget {
+	for i, t := range targets {
+		if t.account == ec.Account && t.obj.Path == ec.Path {
+			return &targets[i]
+		}
+	}
+	if ec.UID != "" {
+		for i, t := range targets {
+			if t.obj.Card.Value(vcard.FieldUID) == ec.UID {
+				return &targets[i]
+			}
+		}
+	}
+	for _, e := range ec.Emails {
+		for i, t := range targets {
+			for _, te := range cardEmails(t.obj.Card) {
+				if te == e {
+					return &targets[i]
+				}
+			}
+		}
+	}
+	for i, t := range targets {
+		if normalizedTokensEqual(contactName(*t.obj), ec.Name) {
+			return &targets[i]
+		}
+	}
+	return nil
+}
+
+func init() {
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Back up contacts, frm metadata, and the interaction log",
+		Long: `Write a single JSON archive of every contact (as vCard, with its
+X-FRM-* metadata broken out) and the full interaction log. Use
+'frm restore' to re-apply it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, _ := cmd.Flags().GetString("output")
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			results, err := allContactsMulti(cfg)
+			if err != nil {
+				return err
+			}
+			entries, err := readLog()
+			if err != nil {
+				return err
+			}
+
+			archive := exportArchive{
+				Version:    exportVersion,
+				ExportedAt: time.Now().UTC(),
+				Contacts:   []exportContact{},
+				Log:        entries,
+			}
+			if archive.Log == nil {
+				archive.Log = []LogEntry{}
+			}
+			for _, r := range results {
+				for _, obj := range r.objs {
+					var buf bytes.Buffer
+					if err := vcard.NewEncoder(&buf).Encode(obj.Card); err != nil {
+						return fmt.Errorf("encoding %s: %w", contactName(obj), err)
+					}
+					archive.Contacts = append(archive.Contacts, exportContact{
+						Account: r.svc.label(),
+						Path:    obj.Path,
+						UID:     obj.Card.Value(vcard.FieldUID),
+						Name:    contactName(obj),
+						Emails:  cardEmails(obj.Card),
+						frmMeta: readMeta(obj.Card),
+						VCard:   buf.String(),
+					})
+				}
+			}
+
+			data, err := json.MarshalIndent(archive, "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshaling archive: %w", err)
+			}
+			data = append(data, '\n')
+			if output == "" || output == "-" {
+				_, err := cmd.OutOrStdout().Write(data)
+				return err
+			}
+			if err := writeFileAtomic(output, data); err != nil {
+				return fmt.Errorf("writing %s: %w", output, err)
+			}
+
+			if isJSONMode(cmd) {
+				return printJSON(cmd, map[string]interface{}{
+					"action":      "export",
+					"path":        output,
+					"contacts":    len(archive.Contacts),
+					"log_entries": len(archive.Log),
+				})
+			}
+			fmt.Printf("Exported %d contacts and %d log entries to %s\n", len(archive.Contacts), len(archive.Log), output)
+			return nil
+		},
+	}
+	exportCmd.Flags().StringP("output", "o", "", "Write the archive to this file instead of stdout")
+	rootCmd.AddCommand(exportCmd)
+
+	restoreCmd := &cobra.Command{
+		Use:   "restore <archive.json>",
+		Short: "Re-apply frm metadata and log entries from an export",
+		Long: `Restore an archive written by 'frm export'. Each exported contact is
+matched to a current card (by path, UID, email, then name) and its
+frequency, ignore, group and snooze are set back to the archived values.
+Log entries missing from the local log are merged in; duplicates are skipped.
+
+Use --dry-run to see what would change.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dryRun := isDryRun(cmd)
+
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			var archive exportArchive
+			if err := json.Unmarshal(data, &archive); err != nil {
+				return fmt.Errorf("parsing %s: %w", args[0], err)
+			}
+			if archive.Version > exportVersion {
+				return fmt.Errorf("%s is from a newer frm (archive version %d); upgrade to restore it", args[0], archive.Version)
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			results, err := allContactsMulti(cfg)
+			if err != nil {
+				return err
+			}
+			var targets []restoreTarget
+			for _, r := range results {
+				for i := range r.objs {
+					targets = append(targets, restoreTarget{account: r.svc.label(), obj: &r.objs[i], client: r.client})
+				}
+			}
+
+			type restoredContact struct {
+				Name    string       `json:"name"`
+				Changes []metaChange `json:"changes"`
+			}
+			var restored []restoredContact
+			var notFound []string
+			conflicts := 0
+			ctx := context.Background()
+			for _, ec := range archive.Contacts {
+				t := matchExported(ec, targets)
+				if t == nil {
+					notFound = append(notFound, ec.Name)
+					continue
+				}
+				changes := readMeta(t.obj.Card).diff(ec.frmMeta)
+				if len(changes) == 0 {
+					continue
+				}
+				name := contactName(*t.obj)
+				if !dryRun {
+					conflicted, err := t.client.updateContact(ctx, t.obj, ec.frmMeta.apply)
+					if err != nil {
+						return fmt.Errorf("restoring %s: %w", name, err)
+					}
+					if conflicted {
+						conflicts++
+						if !isJSONMode(cmd) {
+							fmt.Println(conflictNote(name))
+						}
+					}
+				}
+				restored = append(restored, restoredContact{Name: name, Changes: changes})
+			}
+
+			existing, err := readLog()
+			if err != nil {
+				return err
+			}
+			// An entry is already present if its ID or its content matches,
+			// so entries edited since the export aren't duplicated.
+			seen := make(map[string]bool, 2*len(existing))
+			for _, e := range existing {
+				seen[e.ID] = true
+				seen[logEntryKey(e)] = true
+			}
+			var missing []LogEntry
+			for _, e := range archive.Log {
+				if k := logEntryKey(e); !seen[k] && (e.ID == "" || !seen[e.ID]) {
+					seen[k] = true
+					seen[e.ID] = true
+					missing = append(missing, e)
+				}
+			}
+			sort.SliceStable(missing, func(i, j int) bool { return missing[i].Time.Before(missing[j].Time) })
+			if !dryRun {
+				for _, e := range missing {
+					if err := appendLog(e); err != nil {
+						return err
+					}
+				}
+			}
+
+			if isJSONMode(cmd) {
+				if restored == nil {
+					restored = []restoredContact{}
+				}
+				out := map[string]interface{}{
+					"action":    "restore",
+					"contacts":  restored,
+					"log_added": len(missing),
+				}
+				if len(notFound) > 0 {
+					out["not_found"] = notFound
+				}
+				if conflicts > 0 {
+					out["conflicts"] = conflicts
+				}
+				if dryRun {
+					out["dry_run"] = true
+				}
+				return printJSON(cmd, out)
+			}
+
+			for _, rc := range restored {
+				var parts []string
+				for _, c := range rc.Changes {
+					parts = append(parts, fmt.Sprintf("%s %s -> %s", c.Field, orNone(c.From), orNone(c.To)))
+				}
+				fmt.Printf("%s: %s\n", rc.Name, strings.Join(parts, ", "))
+			}
+			if len(notFound) > 0 {
+				fmt.Printf("Not found: %s\n", strings.Join(notFound, ", "))
+			}
+			if dryRun {
+				fmt.Printf("Would restore metadata on %d contacts and add %d log entries (dry run)\n", len(restored), len(missing))
+			} else {
+				fmt.Printf("Restored metadata on %d contacts and added %d log entries\n", len(restored), len(missing))
+			}
+			return nil
+		},
+	}
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/emersion/go-vcard"
 	"github.com/spf13/cobra"
 )
 
@@ -65,6 +66,16 @@ func groupListRunE(cmd *cobra.Command, args []string) error {
 			}
 		}
 	}
+	// Groups configured in config.json are listed even without members.
+	for name := range cfg.Groups {
+		found := false
+		for g := range groups {
+			found = found || strings.EqualFold(g, name)
+		}
+		if !found {
+			groups[name] = 0
+		}
+	}
 	type kv struct {
 		name  string
 		count int
@@ -82,7 +93,11 @@ func groupListRunE(cmd *cobra.Command, args []string) error {
 		return printJSON(cmd, groups)
 	}
 	for _, g := range sorted {
-		fmt.Printf("  %s (%d)\n", g.name, g.count)
+		if gc, _, ok := cfg.group(g.name); ok {
+			fmt.Printf("  %s (%d): %s\n", g.name, g.count, describeGroup(gc))
+		} else {
+			fmt.Printf("  %s (%d)\n", g.name, g.count)
+		}
 	}
 	if len(sorted) == 0 {
 		fmt.Println("No groups defined")
@@ -90,6 +105,71 @@ func groupListRunE(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// describeGroup summarizes a group's settings, e.g. "every 2w, weight 2".
+func describeGroup(g GroupConfig) string {
+	var parts []string
+	if g.Frequency != "" {
+		parts = append(parts, "every "+g.Frequency)
+	}
+	if g.Weight > 0 {
+		parts = append(parts, fmt.Sprintf("weight %g", g.Weight))
+	}
+	if g.SnoozeUntil != "" {
+		parts = append(parts, "snoozed until "+g.SnoozeUntil)
+	}
+	if len(parts) == 0 {
+		return "no settings"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// updateGroup applies change to a group's settings in config.json, creating
+// the group if needed and dropping it once it has no settings left. It
+// returns the name the group is configured under and its new settings.
+func updateGroup(cmd *cobra.Command, cfg Config, group string, change func(*GroupConfig)) (string, GroupConfig, error) {
+	g, name, ok := cfg.group(group)
+	if !ok {
+		name = group
+	}
+	change(&g)
+	if isDryRun(cmd) {
+		return name, g, nil
+	}
+	if cfg.Groups == nil {
+		cfg.Groups = make(map[string]GroupConfig)
+	}
+	if g == (GroupConfig{}) {
+		delete(cfg.Groups, name)
+	} else {
+		cfg.Groups[name] = g
+	}
+	if err := writeConfig(configPath(), cfg); err != nil {
+		return "", GroupConfig{}, fmt.Errorf("saving config: %w", err)
+	}
+	return name, g, nil
+}
+
+// printGroupChange reports a change to a group's settings.
+func printGroupChange(cmd *cobra.Command, action, name string, g GroupConfig, msg string) error {
+	dryRun := isDryRun(cmd)
+	if isJSONMode(cmd) {
+		out := map[string]interface{}{
+			"action":   action,
+			"group":    name,
+			"settings": g,
+		}
+		if dryRun {
+			out["dry_run"] = true
+		}
+		return printJSON(cmd, out)
+	}
+	if dryRun {
+		msg = "Would " + strings.ToLower(msg[:1]) + msg[1:] + " (dry run)"
+	}
+	fmt.Println(msg)
+	return nil
+}
+
 func init() {
 	setCmd := &cobra.Command{
 		Use:   "set <name> <group>",
@@ -108,13 +188,19 @@ func init() {
 			name := contactName(*matches[0].obj)
 			dryRun := isDryRun(cmd)
 
+			var conflicts int
 			if !dryRun {
 				ctx := context.Background()
 				for _, m := range matches {
-					setGroup(m.obj.Card, args[1])
-					if _, err := m.client.PutAddressObject(ctx, m.obj.Path, m.obj.Card); err != nil {
+					conflicted, err := m.client.updateContact(ctx, m.obj, func(card vcard.Card) {
+						setGroup(card, args[1])
+					})
+					if err != nil {
 						return fmt.Errorf("updating contact: %w", err)
 					}
+					if conflicted {
+						conflicts++
+					}
 				}
 			}
 
@@ -125,6 +211,9 @@ func init() {
 					"group":    args[1],
 					"accounts": len(matches),
 				}
+				if conflicts > 0 {
+					out["conflicts"] = conflicts
+				}
 				if dryRun {
 					out["dry_run"] = true
 				}
@@ -138,6 +227,9 @@ func init() {
 			} else {
 				fmt.Printf("Set %s group to %s\n", name, args[1])
 			}
+			if conflicts > 0 {
+				fmt.Println(conflictNote(name))
+			}
 			return nil
 		},
 	}
@@ -159,13 +251,19 @@ func init() {
 			name := contactName(*matches[0].obj)
 			dryRun := isDryRun(cmd)
 
+			var conflicts int
 			if !dryRun {
 				ctx := context.Background()
 				for _, m := range matches {
-					removeGroup(m.obj.Card)
-					if _, err := m.client.PutAddressObject(ctx, m.obj.Path, m.obj.Card); err != nil {
+					conflicted, err := m.client.updateContact(ctx, m.obj, func(card vcard.Card) {
+						removeGroup(card)
+					})
+					if err != nil {
 						return fmt.Errorf("updating contact: %w", err)
 					}
+					if conflicted {
+						conflicts++
+					}
 				}
 			}
 
@@ -175,6 +273,9 @@ func init() {
 					"name":     name,
 					"accounts": len(matches),
 				}
+				if conflicts > 0 {
+					out["conflicts"] = conflicts
+				}
 				if dryRun {
 					out["dry_run"] = true
 				}
@@ -188,6 +289,9 @@ func init() {
 			} else {
 				fmt.Printf("Removed group from %s\n", name)
 			}
+			if conflicts > 0 {
+				fmt.Println(conflictNote(name))
+			}
 			return nil
 		},
 	}
@@ -213,10 +317,113 @@ func init() {
 		},
 	}
 
+	configCmd := &cobra.Command{
+		Use:   "config <group>",
+		Short: "Show or change a group's default frequency and weight",
+		Long: `Show a group's settings, or change them. Members without a frequency or
+schedule of their own are tracked at the group's default frequency (--every,
+e.g. 2w or 3w-5w), and the weight (--weight) scales how strongly 'frm next'
+favours them. Pass an empty value to clear a setting. Settings are kept in
+the "groups" section of config.json.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if !cmd.Flags().Changed("every") && !cmd.Flags().Changed("weight") {
+				g, name, ok := cfg.group(args[0])
+				if !ok {
+					name = args[0]
+				}
+				if isJSONMode(cmd) {
+					return printJSON(cmd, map[string]interface{}{"group": name, "settings": g})
+				}
+				fmt.Printf("%s: %s\n", name, describeGroup(g))
+				return nil
+			}
+
+			every, _ := cmd.Flags().GetString("every")
+			if every != "" {
+				f, err := parseFrequencyRange(every)
+				if err != nil {
+					return err
+				}
+				every = f.String()
+			}
+			weight, _ := cmd.Flags().GetFloat64("weight")
+			if weight < 0 {
+				return fmt.Errorf("--weight can't be negative")
+			}
+			name, g, err := updateGroup(cmd, cfg, args[0], func(g *GroupConfig) {
+				if cmd.Flags().Changed("every") {
+					g.Frequency = every
+				}
+				if cmd.Flags().Changed("weight") {
+					g.Weight = weight
+				}
+			})
+			if err != nil {
+				return err
+			}
+			return printGroupChange(cmd, "group_config", name, g, fmt.Sprintf("Set group %s: %s", name, describeGroup(g)))
+		},
+	}
+	configCmd.Flags().String("every", "", "Default frequency for members without their own (e.g. 2w, 3w-5w)")
+	configCmd.Flags().Float64("weight", 0, "Weight for 'frm next' (default 1)")
+
+	groupSnoozeCmd := &cobra.Command{
+		Use:   "snooze <group>",
+		Short: "Snooze every contact in a group until a future date",
+		Long:  "Suppress a whole group from check and next until a given date. Use --until with an absolute date (2026-12-01) or relative duration (2m, 6w). Contacts snoozed for longer on their own stay snoozed.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			until, _ := cmd.Flags().GetString("until")
+			if until == "" {
+				return fmt.Errorf("--until is required")
+			}
+			t, err := parseUntil(until)
+			if err != nil {
+				return err
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			name, g, err := updateGroup(cmd, cfg, args[0], func(g *GroupConfig) {
+				g.SnoozeUntil = t.Format("2006-01-02")
+			})
+			if err != nil {
+				return err
+			}
+			return printGroupChange(cmd, "group_snooze", name, g, fmt.Sprintf("Snoozed group %s until %s", name, g.SnoozeUntil))
+		},
+	}
+	groupSnoozeCmd.Flags().String("until", "", "Date to snooze until (e.g. 2026-12-01 or 2m)")
+
+	groupUnsnoozeCmd := &cobra.Command{
+		Use:   "unsnooze <group>",
+		Short: "Remove a group's snooze",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			name, g, err := updateGroup(cmd, cfg, args[0], func(g *GroupConfig) {
+				g.SnoozeUntil = ""
+			})
+			if err != nil {
+				return err
+			}
+			return printGroupChange(cmd, "group_unsnooze", name, g, fmt.Sprintf("Removed snooze from group %s", name))
+		},
+	}
+
 	groupCmd := &cobra.Command{
 		Use:   "group",
 		Short: "Manage contact groups",
 	}
-	groupCmd.AddCommand(setCmd, unsetCmd, listCmd, membersCmd)
+	groupCmd.AddCommand(setCmd, unsetCmd, listCmd, membersCmd, configCmd, groupSnoozeCmd, groupUnsnoozeCmd)
 	rootCmd.AddCommand(groupCmd)
 }
@@ -38,7 +38,10 @@ func init() {
 			}
 
 			for _, e := range found {
-				line := e.Time.Format("2006-01-02")
+				line := shortID(e.ID) + "  " + e.Time.Format("2006-01-02")
+				if d := e.details(); d != "" {
+					line += "  (" + d + ")"
+				}
 				if e.Note != "" {
 					line += "  " + e.Note
 				}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/emersion/go-vcard"
 	"github.com/spf13/cobra"
 )
 
@@ -34,6 +35,7 @@ func init() {
 				updated++
 			}
 
+			var conflicts int
 			if !dryRun && updated > 0 {
 				// Reset counts and actually perform the update
 				updated = 0
@@ -42,10 +44,15 @@ func init() {
 					if isIgnored(m.obj.Card) {
 						continue
 					}
-					setIgnored(m.obj.Card)
-					if _, err := m.client.PutAddressObject(ctx, m.obj.Path, m.obj.Card); err != nil {
+					conflicted, err := m.client.updateContact(ctx, m.obj, func(card vcard.Card) {
+						setIgnored(card)
+					})
+					if err != nil {
 						return fmt.Errorf("updating contact: %w", err)
 					}
+					if conflicted {
+						conflicts++
+					}
 					updated++
 				}
 			}
@@ -57,6 +64,9 @@ func init() {
 					"accounts": updated,
 					"skipped":  skipped,
 				}
+				if conflicts > 0 {
+					out["conflicts"] = conflicts
+				}
 				if dryRun {
 					out["dry_run"] = true
 				}
@@ -76,6 +86,9 @@ func init() {
 			} else {
 				fmt.Printf("Ignored %s\n", name)
 			}
+			if conflicts > 0 {
+				fmt.Println(conflictNote(name))
+			}
 			return nil
 		},
 	})
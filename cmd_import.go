@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+	"github.com/emersion/go-webdav/carddav"
+	"github.com/spf13/cobra"
+)
+
+// importCmd is the parent for importers; each source adds a subcommand.
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import contacts from other sources into CardDAV",
+	Long: `Import contacts from files exported by other services.
+
+Contacts that already exist (matched by email, then by name across all
+accounts) are skipped, or have missing organization/title filled in.
+New contacts are created in the chosen CardDAV account, ready for triage.
+Importing is idempotent, so re-running with a newer export is safe.
+
+--track and --group stamp a frequency and group on every imported contact,
+leaving any an existing contact already has alone.
+
+If any account can't be read, importing stops: contacts that only exist
+there would otherwise be created again as duplicates. Pass --allow-partial
+to import anyway.`,
+}
+
+func init() {
+	importCmd.PersistentFlags().Int("account", 1, "CardDAV account to create contacts in (1 = first configured)")
+	importCmd.PersistentFlags().String("track", "", "Track imported contacts at this frequency (e.g. 3m)")
+	importCmd.PersistentFlags().String("group", "", "Assign imported contacts to this group")
+	importCmd.PersistentFlags().Bool("allow-partial", false, "Import even if some accounts can't be read, which may create duplicates")
+	rootCmd.AddCommand(importCmd)
+}
+
+// importName returns the display name of a card read from an import source.
+func importName(card vcard.Card) string {
+	return contactName(carddav.AddressObject{Card: card})
+}
+
+// importFillFields are copied onto an existing contact when it has none.
+// Identity fields like email and phone are left alone, since a name match
+// may be a different person.
+var importFillFields = []struct {
+	field, label string
+}{
+	{vcard.FieldOrganization, "org"},
+	{vcard.FieldTitle, "title"},
+}
+
+// importStamp is the frequency and group applied to everything imported.
+type importStamp struct {
+	freq  string
+	group string
+}
+
+// fillMissing copies fields from the imported card, and the stamp, onto an
+// existing card where it has none, returning what it filled in.
+func fillMissing(card, imported vcard.Card, stamp importStamp) []string {
+	var added []string
+	for _, f := range importFillFields {
+		if v := strings.TrimSpace(imported.PreferredValue(f.field)); v != "" && card.PreferredValue(f.field) == "" {
+			card[f.field] = []*vcard.Field{{Value: v}}
+			added = append(added, f.label)
+		}
+	}
+	if stamp.freq != "" && !isTracked(card) && !isIgnored(card) {
+		setFrequency(card, stamp.freq)
+		added = append(added, "frequency")
+	}
+	if stamp.group != "" && getGroup(card) == "" {
+		setGroup(card, stamp.group)
+		added = append(added, "group")
+	}
+	return added
+}
+
+// importResult records what happened to one imported record.
+type importResult struct {
+	Name   string   `json:"name"`
+	Result string   `json:"result"`
+	Added  []string `json:"added,omitempty"`
+}
+
+// importIndex finds existing contacts by email or name.
+type importIndex struct {
+	byEmail map[string]contactMatch
+	all     []contactMatch
+}
+
+func newImportIndex() *importIndex {
+	return &importIndex{byEmail: make(map[string]contactMatch)}
+}
+
+func (idx *importIndex) add(obj *carddav.AddressObject, client *davClient) {
+	m := contactMatch{obj: obj, client: client}
+	for _, f := range obj.Card[vcard.FieldEmail] {
+		if e := strings.ToLower(strings.TrimSpace(f.Value)); e != "" {
+			if _, ok := idx.byEmail[e]; !ok {
+				idx.byEmail[e] = m
+			}
+		}
+	}
+	idx.all = append(idx.all, m)
+}
+
+// find prefers an email match, since names collide far more often.
+func (idx *importIndex) find(card vcard.Card) (contactMatch, bool) {
+	for _, f := range card[vcard.FieldEmail] {
+		if m, ok := idx.byEmail[strings.ToLower(strings.TrimSpace(f.Value))]; ok {
+			return m, true
+		}
+	}
+	name := importName(card)
+	for _, m := range idx.all {
+		if normalizedTokensEqual(contactName(*m.obj), name) {
+			return m, true
+		}
+	}
+	return contactMatch{}, false
+}
+
+// runImport creates or updates contacts for the imported cards and prints a
+// summary. what describes them for the progress line, e.g. "LinkedIn connections".
+func runImport(cmd *cobra.Command, source, what string, cards []vcard.Card) error {
+	account, _ := cmd.Flags().GetInt("account")
+	var stamp importStamp
+	stamp.freq, _ = cmd.Flags().GetString("track")
+	stamp.group, _ = cmd.Flags().GetString("group")
+	allowPartial, _ := cmd.Flags().GetBool("allow-partial")
+	dryRun := isDryRun(cmd)
+	jsonMode := isJSONMode(cmd)
+
+	if stamp.freq != "" {
+		f, err := parseFrequencyRange(stamp.freq)
+		if err != nil {
+			return err
+		}
+		stamp.freq = f.String()
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	svcs := cfg.carddavServices()
+	if len(svcs) == 0 {
+		return fmt.Errorf("no CardDAV services configured")
+	}
+	if account < 1 || account > len(svcs) {
+		return fmt.Errorf("--account must be between 1 and %d", len(svcs))
+	}
+	target := svcs[account-1]
+
+	results, err := allContactsMulti(cfg)
+	if err != nil {
+		return err
+	}
+	idx := newImportIndex()
+	var client *davClient
+	for _, r := range results {
+		if r.svc.Endpoint == target.Endpoint && r.svc.Username == target.Username {
+			client = r.client
+		}
+		for i := range r.objs {
+			idx.add(&r.objs[i], r.client)
+		}
+	}
+	if client == nil {
+		return fmt.Errorf("could not read target account %s", target.label())
+	}
+	// Matching only sees the accounts that loaded, so anyone who lives
+	// only in a skipped one would be imported again as a duplicate.
+	if len(accountErrors) > 0 && !allowPartial {
+		var skipped []string
+		for _, e := range accountErrors {
+			skipped = append(skipped, e.Account)
+		}
+		return fmt.Errorf("could not read %s, so existing contacts there can't be matched; fix the account or pass --allow-partial to import anyway", strings.Join(skipped, ", "))
+	}
+
+	ctx := context.Background()
+	book, err := findAddressBook(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if !jsonMode {
+		fmt.Printf("Importing %d %s...\n", len(cards), what)
+	}
+
+	var out []importResult
+	var created, updated, skipped int
+	for _, imported := range cards {
+		name := importName(imported)
+		if name == "" {
+			skipped++
+			continue
+		}
+
+		if m, ok := idx.find(imported); ok {
+			// Fill in on a copy so a dry run leaves the indexed card untouched.
+			added := fillMissing(copyCard(m.obj.Card), imported, stamp)
+			if len(added) == 0 {
+				skipped++
+				out = append(out, importResult{Name: name, Result: "skipped"})
+				continue
+			}
+			if !dryRun {
+				conflicted, err := m.client.updateContact(ctx, m.obj, func(card vcard.Card) { fillMissing(card, imported, stamp) })
+				if err != nil {
+					return fmt.Errorf("updating %s: %w", name, err)
+				}
+				if conflicted && !jsonMode {
+					fmt.Println("  " + conflictNote(name))
+				}
+			} else if !jsonMode {
+				fmt.Printf("  Would update %s (add %s)\n", name, strings.Join(added, ", "))
+			}
+			updated++
+			out = append(out, importResult{Name: name, Result: "updated", Added: added})
+			continue
+		}
+
+		card := copyCard(imported)
+		if card.PreferredValue(vcard.FieldFormattedName) == "" {
+			card[vcard.FieldFormattedName] = []*vcard.Field{{Value: name}}
+		}
+		if stamp.freq != "" {
+			setFrequency(card, stamp.freq)
+		}
+		if stamp.group != "" {
+			setGroup(card, stamp.group)
+		}
+		obj := &carddav.AddressObject{Path: book.Path + newUUID() + ".vcf", Card: card}
+		if !dryRun {
+			written, err := client.PutAddressObject(ctx, obj.Path, card)
+			if err != nil {
+				return fmt.Errorf("creating %s: %w", name, err)
+			}
+			obj.ETag = written.ETag
+		} else if !jsonMode {
+			fmt.Printf("  Would create %s\n", name)
+		}
+		// Index the new contact so duplicate rows in the same file are skipped.
+		idx.add(obj, client)
+		created++
+		out = append(out, importResult{Name: name, Result: "created"})
+	}
+
+	if jsonMode {
+		result := map[string]interface{}{
+			"action":   "import",
+			"source":   source,
+			"account":  target.label(),
+			"total":    len(cards),
+			"created":  created,
+			"updated":  updated,
+			"skipped":  skipped,
+			"contacts": out,
+		}
+		if stamp.freq != "" {
+			result["frequency"] = stamp.freq
+		}
+		if stamp.group != "" {
+			result["group"] = stamp.group
+		}
+		if dryRun {
+			result["dry_run"] = true
+		}
+		return printJSON(cmd, result)
+	}
+
+	fmt.Printf("  Created: %d\n", created)
+	fmt.Printf("  Updated: %d (filled in missing fields)\n", updated)
+	fmt.Printf("  Skipped: %d (already exist)\n", skipped)
+	if dryRun {
+		fmt.Println("Done (dry run, nothing written).")
+	} else {
+		fmt.Println("Done.")
+	}
+	return nil
+}
+
+// copyCard returns a shallow copy of card whose field lists can be replaced
+// without affecting the original.
+func copyCard(card vcard.Card) vcard.Card {
+	c := make(vcard.Card, len(card))
+	for k, v := range card {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+	"github.com/spf13/cobra"
+)
+
+// Pseudo-properties for --map that make up the N field rather than a vCard
+// property of their own.
+const (
+	csvGiven  = "GIVEN"
+	csvFamily = "FAMILY"
+)
+
+// csvPresets map the column headers of common exports. Columns missing from
+// a file are ignored, since exports vary between versions.
+var csvPresets = map[string][][2]string{
+	"google": {
+		{"Name", vcard.FieldFormattedName},
+		{"Given Name", csvGiven},
+		{"Family Name", csvFamily},
+		{"First Name", csvGiven},
+		{"Last Name", csvFamily},
+		{"E-mail 1 - Value", vcard.FieldEmail},
+		{"E-mail 2 - Value", vcard.FieldEmail},
+		{"Phone 1 - Value", vcard.FieldTelephone},
+		{"Phone 2 - Value", vcard.FieldTelephone},
+		{"Organization 1 - Name", vcard.FieldOrganization},
+		{"Organization 1 - Title", vcard.FieldTitle},
+		{"Organization Name", vcard.FieldOrganization},
+		{"Organization Title", vcard.FieldTitle},
+		{"Website 1 - Value", vcard.FieldURL},
+		{"Notes", vcard.FieldNote},
+	},
+	"outlook": {
+		{"First Name", csvGiven},
+		{"Last Name", csvFamily},
+		{"E-mail Address", vcard.FieldEmail},
+		{"E-mail 2 Address", vcard.FieldEmail},
+		{"Mobile Phone", vcard.FieldTelephone},
+		{"Business Phone", vcard.FieldTelephone},
+		{"Home Phone", vcard.FieldTelephone},
+		{"Company", vcard.FieldOrganization},
+		{"Job Title", vcard.FieldTitle},
+		{"Web Page", vcard.FieldURL},
+		{"Notes", vcard.FieldNote},
+	},
+}
+
+var vcardPropRe = regexp.MustCompile(`^[A-Z][A-Z0-9-]*$`)
+
+// parseCSVMap parses a --map value like "Name=FN,Mail=EMAIL" into
+// column/property pairs.
+func parseCSVMap(s string) ([][2]string, error) {
+	var pairs [][2]string
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		col, prop, ok := strings.Cut(entry, "=")
+		col, prop = strings.TrimSpace(col), strings.ToUpper(strings.TrimSpace(prop))
+		if !ok || col == "" || !vcardPropRe.MatchString(prop) {
+			return nil, fmt.Errorf("invalid --map entry %q: expected Column=PROPERTY", entry)
+		}
+		pairs = append(pairs, [2]string{col, prop})
+	}
+	return pairs, nil
+}
+
+// csvHeaderKey normalizes a column header for lookup, dropping the byte
+// order mark some exporters write at the start of the file.
+func csvHeaderKey(h string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+}
+
+// csvColumn is a header index and the property its values go to.
+type csvColumn struct {
+	index int
+	prop  string
+}
+
+// parseMappedCSV reads a CSV with a header row, building a card per row from
+// the preset and explicit column mappings. Explicit columns must exist.
+func parseMappedCSV(r io.Reader, preset, explicit [][2]string) ([]vcard.Card, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	headerIdx := make(map[string]int, len(header))
+	for i, h := range header {
+		headerIdx[csvHeaderKey(h)] = i
+	}
+
+	// Later mappings for the same column win, so --map overrides a preset.
+	byCol := make(map[int]string)
+	var order []int
+	addCol := func(col, prop string, required bool) error {
+		i, ok := headerIdx[csvHeaderKey(col)]
+		if !ok {
+			if required {
+				return fmt.Errorf("column %q not found in CSV header", col)
+			}
+			return nil
+		}
+		if _, seen := byCol[i]; !seen {
+			order = append(order, i)
+		}
+		byCol[i] = prop
+		return nil
+	}
+	for _, p := range preset {
+		if err := addCol(p[0], p[1], false); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range explicit {
+		if err := addCol(p[0], p[1], true); err != nil {
+			return nil, err
+		}
+	}
+	var cols []csvColumn
+	hasName := false
+	for _, i := range order {
+		prop := byCol[i]
+		cols = append(cols, csvColumn{index: i, prop: prop})
+		if prop == vcard.FieldFormattedName || prop == csvGiven || prop == csvFamily {
+			hasName = true
+		}
+	}
+	if !hasName {
+		return nil, fmt.Errorf("no name column mapped; map one to FN, or to GIVEN and FAMILY")
+	}
+
+	var cards []vcard.Card
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV: %w", err)
+		}
+		cards = append(cards, csvRowCard(row, cols))
+	}
+	return cards, nil
+}
+
+// csvRowCard builds a card from one row. Google joins multiple values in a
+// cell with " ::: ", so those become separate fields.
+func csvRowCard(row []string, cols []csvColumn) vcard.Card {
+	var name, given, family string
+	fields := make(map[string][]*vcard.Field)
+	var props []string
+	for _, c := range cols {
+		if c.index >= len(row) {
+			continue
+		}
+		for _, v := range strings.Split(row[c.index], " ::: ") {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			switch c.prop {
+			case vcard.FieldFormattedName:
+				if name == "" {
+					name = v
+				}
+			case csvGiven:
+				if given == "" {
+					given = v
+				}
+			case csvFamily:
+				if family == "" {
+					family = v
+				}
+			default:
+				if _, ok := fields[c.prop]; !ok {
+					props = append(props, c.prop)
+				}
+				fields[c.prop] = append(fields[c.prop], &vcard.Field{Value: v})
+			}
+		}
+	}
+
+	if name == "" {
+		name = strings.TrimSpace(given + " " + family)
+	}
+	if name == "" {
+		return vcard.Card{}
+	}
+	card := newContactCard(name)
+	if given != "" || family != "" {
+		card[vcard.FieldName] = []*vcard.Field{{Value: family + ";" + given + ";;;"}}
+	}
+	for _, p := range props {
+		card[p] = fields[p]
+	}
+	return card
+}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "csv <file>",
+		Short: "Import contacts from a CSV file with a column mapping",
+		Long: `Import contacts from a CSV file with a header row.
+
+--map maps column headers to vCard properties, e.g.
+  --map "Name=FN,Mail=EMAIL,Phone=TEL,Company=ORG"
+Use GIVEN and FAMILY for separate first/last name columns. Mapping several
+columns to the same property (e.g. two email columns) keeps them all.
+
+--preset google or --preset outlook maps the columns of those exports;
+--map entries are applied on top.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mapFlag, _ := cmd.Flags().GetString("map")
+			presetName, _ := cmd.Flags().GetString("preset")
+			if mapFlag == "" && presetName == "" {
+				return fmt.Errorf("--map or --preset is required")
+			}
+
+			var preset [][2]string
+			if presetName != "" {
+				var ok bool
+				preset, ok = csvPresets[strings.ToLower(presetName)]
+				if !ok {
+					return fmt.Errorf("unknown preset %q (use google or outlook)", presetName)
+				}
+			}
+			explicit, err := parseCSVMap(mapFlag)
+			if err != nil {
+				return err
+			}
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			cards, err := parseMappedCSV(f, preset, explicit)
+			if err != nil {
+				return fmt.Errorf("parsing %s: %w", args[0], err)
+			}
+			return runImport(cmd, "csv", "contacts", cards)
+		},
+	}
+	cmd.Flags().String("map", "", `Column mapping, e.g. "Name=FN,Mail=EMAIL"`)
+	cmd.Flags().String("preset", "", "Column mapping for a known export: google or outlook")
+	importCmd.AddCommand(cmd)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-vcard"
+	"github.com/spf13/cobra"
+)
+
+// parseLinkedInCSV reads a LinkedIn "Connections.csv" export. The file
+// starts with a few lines of notes before the header row, so rows are
+// skipped until one with a "First Name" column appears.
+func parseLinkedInCSV(r io.Reader) ([]vcard.Card, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	var cols map[string]int
+	var cards []vcard.Card
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV: %w", err)
+		}
+		if cols == nil {
+			cols = linkedInHeader(row)
+			continue
+		}
+
+		get := func(col string) string {
+			if i, ok := cols[col]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		given, family := get("first name"), get("last name")
+		card := newContactCard(strings.TrimSpace(given + " " + family))
+		card[vcard.FieldName] = []*vcard.Field{{Value: family + ";" + given + ";;;"}}
+		for field, col := range map[string]string{
+			vcard.FieldOrganization: "company",
+			vcard.FieldTitle:        "position",
+			vcard.FieldEmail:        "email address",
+			vcard.FieldURL:          "url",
+		} {
+			if v := get(col); v != "" {
+				card[field] = []*vcard.Field{{Value: v}}
+			}
+		}
+		if on := get("connected on"); on != "" {
+			if t, err := time.Parse("02 Jan 2006", on); err == nil {
+				on = t.Format("2006-01-02")
+			}
+			card[vcard.FieldNote] = []*vcard.Field{{Value: "Connected on LinkedIn: " + on}}
+		}
+		cards = append(cards, card)
+	}
+	if cols == nil {
+		return nil, fmt.Errorf("no header row found; expected a LinkedIn Connections.csv with a \"First Name\" column")
+	}
+	return cards, nil
+}
+
+// linkedInHeader maps lowercased column names to indexes, or returns nil if
+// row isn't the header.
+func linkedInHeader(row []string) map[string]int {
+	cols := make(map[string]int, len(row))
+	for i, c := range row {
+		cols[csvHeaderKey(c)] = i
+	}
+	if _, ok := cols["first name"]; !ok {
+		return nil
+	}
+	return cols
+}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "linkedin <Connections.csv>",
+		Short: "Import connections from a LinkedIn data export",
+		Long: `Import connections from LinkedIn's Connections.csv, found in the archive
+from Settings > Data Privacy > Get a copy of your data.
+
+Each connection becomes a contact with ORG, TITLE, EMAIL (when shared) and
+a NOTE recording the connection date. Existing contacts are matched by email,
+then by name; they're skipped, or get org/title filled in if missing.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			cards, err := parseLinkedInCSV(f)
+			if err != nil {
+				return fmt.Errorf("parsing %s: %w", args[0], err)
+			}
+			return runImport(cmd, "linkedin", "LinkedIn connections", cards)
+		},
+	}
+	importCmd.AddCommand(cmd)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/emersion/go-vcard"
+	"github.com/spf13/cobra"
+)
+
+// parseVCFFile reads every card from a .vcf bundle.
+func parseVCFFile(r io.Reader) ([]vcard.Card, error) {
+	dec := vcard.NewDecoder(r)
+	var cards []vcard.Card
+	for {
+		card, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding card %d: %w", len(cards)+1, err)
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "vcf <file>",
+		Short: "Import contacts from a .vcf file",
+		Long: `Import every card in a .vcf file, such as one exported from a phone
+or another address book. New contacts are created with all their fields;
+existing ones are matched by email, then by name.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			cards, err := parseVCFFile(f)
+			if err != nil {
+				return fmt.Errorf("parsing %s: %w", args[0], err)
+			}
+			return runImport(cmd, "vcf", "contacts", cards)
+		},
+	}
+	importCmd.AddCommand(cmd)
+}
@@ -32,7 +32,13 @@ func writeConfig(path string, cfg Config) error {
 	if err != nil {
 		return fmt.Errorf("marshaling config: %w", err)
 	}
-	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.json")
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic replaces path with data via a synced temp file + rename,
+// so readers never observe a partially-written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
 	if err != nil {
 		return fmt.Errorf("creating temp file: %w", err)
 	}
@@ -40,20 +46,20 @@ func writeConfig(path string, cfg Config) error {
 	if _, err := tmp.Write(data); err != nil {
 		tmp.Close()
 		os.Remove(tmpPath)
-		return fmt.Errorf("writing config: %w", err)
+		return fmt.Errorf("writing %s: %w", filepath.Base(path), err)
 	}
 	if err := tmp.Sync(); err != nil {
 		tmp.Close()
 		os.Remove(tmpPath)
-		return fmt.Errorf("syncing config: %w", err)
+		return fmt.Errorf("syncing %s: %w", filepath.Base(path), err)
 	}
 	if err := tmp.Close(); err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("closing config: %w", err)
+		return fmt.Errorf("closing %s: %w", filepath.Base(path), err)
 	}
 	if err := os.Rename(tmpPath, path); err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("renaming config: %w", err)
+		return fmt.Errorf("renaming %s: %w", filepath.Base(path), err)
 	}
 	return nil
 }
@@ -85,6 +91,9 @@ func runInit(r io.Reader, w io.Writer) error {
 		}
 	}
 
+	// Adding a service keeps everything else in the config, such as group
+	// settings.
+	var cfg Config
 	var services []ServiceConfig
 
 	if existing != nil {
@@ -95,6 +104,7 @@ func runInit(r io.Reader, w io.Writer) error {
 		}
 		switch strings.ToLower(answer) {
 		case "a", "add":
+			cfg = *existing
 			services = existing.Services
 		case "o", "overwrite":
 			// start fresh
@@ -130,7 +140,7 @@ func runInit(r io.Reader, w io.Writer) error {
 		return fmt.Errorf("unknown service type %q", svcType)
 	}
 
-	cfg := Config{Services: services}
+	cfg.Services = services
 
 	// Write config
 	if err := os.MkdirAll(dir, 0o755); err != nil {
@@ -11,8 +11,15 @@ import (
 type listEntry struct {
 	Name      string `json:"name"`
 	Frequency string `json:"frequency,omitempty"`
+	Schedule  string `json:"schedule,omitempty"`
+	FromGroup bool   `json:"frequency_from_group,omitempty"` // frequency is the group's default
 	Group     string `json:"group,omitempty"`
-	DueIn     *int   `json:"due_in_days,omitempty"`
+	DueIn     *int   `json:"due_in_days,omitempty"` // until due_by, or the end of a snooze
+	DueFrom   string `json:"due_from,omitempty"`    // when a window like 3w-5w opens; due_by otherwise
+	DueBy     string `json:"due_by,omitempty"`      // overdue after this
+
+	cadence string // FREQ column text; the frequency unless on a schedule
+	window  string // DUE column text for a window, with both dates
 }
 
 func init() {
@@ -45,37 +52,39 @@ func init() {
 					if name == "" {
 						continue
 					}
-					freq := getFrequency(obj.Card)
-					if !all && (freq == "" || isIgnored(obj.Card)) {
+					if !all && (!cfg.tracks(obj.Card) || isIgnored(obj.Card)) {
 						continue
 					}
 
 					e := listEntry{
 						Name:      name,
-						Frequency: freq,
+						Frequency: getFrequency(obj.Card),
 						Group:     getGroup(obj.Card),
 					}
+					e.Schedule, _ = getSchedule(obj.Card)
+					e.cadence = e.Frequency
 
-					if freq != "" {
-						dur, err := parseDuration(freq)
-						if err == nil {
-							// Check snooze first
-							if snoozeUntil, ok := getSnoozeUntil(obj.Card); ok && now.Before(snoozeUntil) {
-								days := int(snoozeUntil.Sub(now).Hours() / 24)
-								e.DueIn = &days
-							} else {
-								last, ok := lastContact[obj.Path]
-								if !ok {
-									last, ok = lastContact[name]
-								}
-								if ok {
-									days := int((dur - now.Sub(last)).Hours() / 24)
-									e.DueIn = &days
-								} else {
-									days := 0
-									e.DueIn = &days
-								}
+					if d, ok := dueFor(cfg, obj, lastContact, now); ok {
+						days := d.daysUntil(now)
+						e.DueIn = &days
+						e.DueFrom = d.DueFrom.Format("2006-01-02")
+						e.DueBy = d.Due.Format("2006-01-02")
+						switch {
+						case d.Schedule != nil:
+							e.cadence = d.Schedule.String()
+						case d.FromGroup:
+							e.Frequency, e.FromGroup = d.Frequency, true
+							e.cadence = d.Frequency + " (group)"
+						}
+						if d.DueFrom.Before(d.Due) && !d.snoozed() {
+							status := fmt.Sprintf("in %dd", daysFrom(now, d.DueFrom))
+							switch {
+							case d.overdue(now):
+								status = fmt.Sprintf("overdue %dd", -days)
+							case d.dueSoon(now):
+								status = "due soon"
 							}
+							e.window = fmt.Sprintf("%s to %s (%s)", e.DueFrom, e.DueBy, status)
 						}
 					}
 
@@ -102,8 +111,8 @@ func init() {
 				if len(e.Name) > nameW {
 					nameW = len(e.Name)
 				}
-				if len(e.Frequency) > freqW {
-					freqW = len(e.Frequency)
+				if len(e.cadence) > freqW {
+					freqW = len(e.cadence)
 				}
 				if len(e.Group) > groupW {
 					groupW = len(e.Group)
@@ -113,8 +122,8 @@ func init() {
 			fmtStr := fmt.Sprintf("%%-%ds  %%-%ds  %%-%ds  %%s\n", nameW, freqW, groupW)
 			fmt.Printf(fmtStr, "NAME", "FREQ", "GROUP", "DUE")
 			for _, e := range list {
-				due := ""
-				if e.DueIn != nil {
+				due := e.window
+				if due == "" && e.DueIn != nil {
 					days := *e.DueIn
 					if days < 0 {
 						due = fmt.Sprintf("overdue %dd", -days)
@@ -124,7 +133,7 @@ func init() {
 						due = fmt.Sprintf("in %dd", days)
 					}
 				}
-				fmt.Printf(fmtStr, e.Name, e.Frequency, e.Group, due)
+				fmt.Printf(fmtStr, e.Name, e.cadence, e.Group, due)
 			}
 			return nil
 		},
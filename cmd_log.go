@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -13,24 +15,13 @@ func init() {
 		Short: "Log an interaction with a contact",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			note, _ := cmd.Flags().GetString("note")
-			when, _ := cmd.Flags().GetString("when")
-
-			var ts time.Time
-			if when != "" {
-				var err error
-				ts, err = parseWhen(when)
-				if err != nil {
-					return err
-				}
-			} else {
-				ts = time.Now().UTC()
-			}
-
 			entry := LogEntry{
+				ID:      newLogID(),
 				Contact: args[0],
-				Time:    ts,
-				Note:    note,
+				Time:    time.Now().UTC(),
+			}
+			if err := applyLogFlags(cmd, &entry); err != nil {
+				return err
 			}
 
 			// Try to resolve the contact path for name normalization
@@ -54,10 +45,23 @@ func init() {
 			if isJSONMode(cmd) {
 				out := map[string]interface{}{
 					"action":  "log",
+					"id":      entry.ID,
 					"contact": entry.Contact,
 					"time":    entry.Time.Format(time.RFC3339),
 					"note":    entry.Note,
 				}
+				if entry.Via != "" {
+					out["via"] = entry.Via
+				}
+				if entry.Direction != "" {
+					out["direction"] = entry.Direction
+				}
+				if entry.DurationMin > 0 {
+					out["duration_min"] = entry.DurationMin
+				}
+				if entry.Location != "" {
+					out["location"] = entry.Location
+				}
 				if dryRun {
 					out["dry_run"] = true
 				}
@@ -72,7 +76,290 @@ func init() {
 			return nil
 		},
 	}
-	logCmd.Flags().String("note", "", "Note about the interaction")
-	logCmd.Flags().String("when", "", "When it happened (YYYY-MM-DD, e.g. 2024-01-15)")
+	addLogFlags(logCmd)
+
+	editCmd := &cobra.Command{
+		Use:   "edit <id>",
+		Short: "Change fields of a logged interaction",
+		Long: `Change fields of a logged interaction. Only the flags given are changed;
+pass an empty value (e.g. --location "") to clear a field. IDs are shown by
+'frm history' and may be shortened to any unique prefix.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !logFlagsChanged(cmd) {
+				return fmt.Errorf("nothing to change; pass --note, --when, --via, --direction, --duration or --location")
+			}
+			var updated LogEntry
+			old, err := updateLogEntry(args[0], isDryRun(cmd), func(e LogEntry) (*LogEntry, error) {
+				if err := applyLogFlags(cmd, &e); err != nil {
+					return nil, err
+				}
+				updated = e
+				return &e, nil
+			})
+			if err != nil {
+				return err
+			}
+			return printLogChange(cmd, "log edit", old, &updated, fmt.Sprintf("Updated log entry %s for %s", shortID(old.ID), old.Contact))
+		},
+	}
+	addLogFlags(editCmd)
+
+	rmCmd := &cobra.Command{
+		Use:     "rm <id>",
+		Aliases: []string{"delete"},
+		Short:   "Delete a logged interaction",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			old, err := updateLogEntry(args[0], isDryRun(cmd), func(LogEntry) (*LogEntry, error) { return nil, nil })
+			if err != nil {
+				return err
+			}
+			return printLogChange(cmd, "log rm", old, nil, fmt.Sprintf("Removed log entry %s for %s", shortID(old.ID), old.Contact))
+		},
+	}
+
+	moveCmd := &cobra.Command{
+		Use:   "move <id> <contact>",
+		Short: "Move a logged interaction to another contact",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			contact, path := args[1], ""
+			if cfg, err := loadConfig(); err == nil {
+				obj, _, err := findContactMulti(cfg, args[1])
+				if err != nil {
+					return err
+				}
+				contact, path = contactName(*obj), obj.Path
+			}
+
+			var updated LogEntry
+			old, err := updateLogEntry(args[0], isDryRun(cmd), func(e LogEntry) (*LogEntry, error) {
+				e.Contact, e.Path = contact, path
+				updated = e
+				return &e, nil
+			})
+			if err != nil {
+				return err
+			}
+			return printLogChange(cmd, "log move", old, &updated, fmt.Sprintf("Moved log entry %s from %s to %s", shortID(old.ID), old.Contact, contact))
+		},
+	}
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check the interaction log for malformed lines",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var lines []logLine
+			if err := withLogReadLock(func() error {
+				var err error
+				lines, err = readLogLines()
+				return err
+			}); err != nil {
+				return err
+			}
+			bad := malformedLogLines(lines)
+
+			if isJSONMode(cmd) {
+				return printJSON(cmd, map[string]interface{}{
+					"path":      logFilePath(),
+					"ok":        len(bad) == 0,
+					"entries":   len(lines) - len(bad),
+					"malformed": bad,
+				})
+			}
+			fmt.Printf("%s: %d entries, %d malformed lines\n", logFilePath(), len(lines)-len(bad), len(bad))
+			if len(bad) == 0 {
+				return nil
+			}
+			for _, b := range bad {
+				fmt.Printf("  line %d: %s: %s\n", b.Line, b.Error, b.Text)
+			}
+			fmt.Println("Run 'frm log repair' to move them to " + logQuarantinePath())
+			return fmt.Errorf("found %d malformed lines in the log", len(bad))
+		},
+	}
+
+	repairCmd := &cobra.Command{
+		Use:   "repair",
+		Short: "Move malformed log lines into a quarantine file",
+		Long: `Move lines of the interaction log that aren't valid entries into
+log.jsonl.quarantine next to it, so they stop being skipped on every read
+but can still be recovered by hand.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dryRun := isDryRun(cmd)
+			lines, err := quarantineLogLines(dryRun)
+			if err != nil {
+				return err
+			}
+			bad := malformedLogLines(lines)
+
+			if isJSONMode(cmd) {
+				out := map[string]interface{}{
+					"action":      "log repair",
+					"quarantined": bad,
+					"quarantine":  logQuarantinePath(),
+				}
+				if dryRun {
+					out["dry_run"] = true
+				}
+				return printJSON(cmd, out)
+			}
+			if len(bad) == 0 {
+				fmt.Println("No malformed lines; nothing to repair.")
+				return nil
+			}
+			for _, b := range bad {
+				fmt.Printf("  line %d: %s\n", b.Line, b.Text)
+			}
+			if dryRun {
+				fmt.Printf("Would quarantine %d lines to %s (dry run)\n", len(bad), logQuarantinePath())
+			} else {
+				fmt.Printf("Quarantined %d lines to %s\n", len(bad), logQuarantinePath())
+			}
+			return nil
+		},
+	}
+
+	logCmd.AddCommand(editCmd, rmCmd, moveCmd, verifyCmd, repairCmd)
 	rootCmd.AddCommand(logCmd)
 }
+
+// logFlagNames are the entry fields settable by 'log' and 'log edit'.
+var logFlagNames = []string{"note", "when", "via", "direction", "duration", "location"}
+
+func logFlagsChanged(cmd *cobra.Command) bool {
+	for _, name := range logFlagNames {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func addLogFlags(cmd *cobra.Command) {
+	cmd.Flags().String("note", "", "Note about the interaction")
+	cmd.Flags().String("when", "", "When it happened (YYYY-MM-DD, e.g. 2024-01-15)")
+	cmd.Flags().String("via", "", "How you connected: call, text, email, in-person, video, ...")
+	cmd.Flags().String("direction", "", "Who reached out: in (they did) or out (you did)")
+	cmd.Flags().String("duration", "", "How long it lasted (e.g. 45m, 1h30m, or minutes)")
+	cmd.Flags().String("location", "", "Where it happened")
+}
+
+// applyLogFlags sets the entry fields whose flags were given. An empty value
+// clears the field.
+func applyLogFlags(cmd *cobra.Command, e *LogEntry) error {
+	flags := cmd.Flags()
+	get := func(name string) (string, bool) {
+		v, _ := flags.GetString(name)
+		return v, flags.Changed(name)
+	}
+
+	if v, ok := get("note"); ok {
+		e.Note = v
+	}
+	if v, ok := get("when"); ok {
+		ts, err := parseWhen(v)
+		if err != nil {
+			return err
+		}
+		e.Time = ts
+	}
+	if v, ok := get("via"); ok {
+		e.Via = ""
+		if v != "" {
+			via, err := parseVia(v)
+			if err != nil {
+				return err
+			}
+			e.Via = via
+		}
+	}
+	if v, ok := get("direction"); ok {
+		e.Direction = ""
+		if v != "" {
+			d, err := parseDirection(v)
+			if err != nil {
+				return err
+			}
+			e.Direction = d
+		}
+	}
+	if v, ok := get("duration"); ok {
+		e.DurationMin = 0
+		if v != "" {
+			m, err := parseMinutes(v)
+			if err != nil {
+				return err
+			}
+			e.DurationMin = m
+		}
+	}
+	if v, ok := get("location"); ok {
+		e.Location = strings.TrimSpace(v)
+	}
+	return nil
+}
+
+// printLogChange reports an edit, removal or move of a log entry.
+func printLogChange(cmd *cobra.Command, action string, old LogEntry, updated *LogEntry, msg string) error {
+	dryRun := isDryRun(cmd)
+	if isJSONMode(cmd) {
+		out := map[string]interface{}{
+			"action": action,
+			"id":     old.ID,
+			"before": old,
+		}
+		if updated != nil {
+			out["after"] = *updated
+		}
+		if dryRun {
+			out["dry_run"] = true
+		}
+		return printJSON(cmd, out)
+	}
+	if dryRun {
+		fmt.Printf("Would have: %s (dry run)\n", msg)
+	} else {
+		fmt.Println(msg)
+	}
+	return nil
+}
+
+// parseMinutes parses an interaction length like "45m" or "1h30m", or a bare
+// number of minutes. Note "m" is minutes here, unlike frequencies.
+func parseMinutes(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return n, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Minute {
+		return 0, fmt.Errorf("invalid duration %q (e.g. 45m, 1h30m)", s)
+	}
+	return int(d.Round(time.Minute) / time.Minute), nil
+}
+
+// malformedLine describes a log line that isn't a valid entry.
+type malformedLine struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+	Text  string `json:"text"`
+}
+
+func malformedLogLines(lines []logLine) []malformedLine {
+	bad := []malformedLine{}
+	for _, l := range lines {
+		if l.entry != nil {
+			continue
+		}
+		text := string(l.raw)
+		if len(text) > 60 {
+			text = text[:60] + "..."
+		}
+		bad = append(bad, malformedLine{Line: l.num, Error: l.err.Error(), Text: text})
+	}
+	return bad
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// recencyWeight is the most a long silence adds to a score, reached
+	// after recencyHorizon without any interaction.
+	recencyWeight  = 0.5
+	recencyHorizon = 365 * 24 * time.Hour
+
+	// eventWeight is what a birthday or anniversary today adds to a score,
+	// tapering to nothing eventHorizonDays out.
+	eventWeight      = 1.0
+	eventHorizonDays = 14
+)
+
+// scoreBreakdown is how a suggestion's score was reached:
+// score = overdue * group_weight + recency + upcoming.
+type scoreBreakdown struct {
+	Overdue     float64 `json:"overdue"`      // time since last contact / frequency; 1 if never contacted
+	GroupWeight float64 `json:"group_weight"` // from the contact's group in config, default 1
+	Recency     float64 `json:"recency"`      // 0 to 0.5, growing over a year without any interaction
+	Upcoming    float64 `json:"upcoming"`     // 0 to 1 for a birthday or anniversary in the next two weeks
+}
+
+func (b scoreBreakdown) score() float64 {
+	return b.Overdue*b.GroupWeight + b.Recency + b.Upcoming
+}
+
+// suggestion is a contact 'frm next' proposes reaching out to.
+type suggestion struct {
+	Name      string         `json:"name"`
+	Score     float64        `json:"score"`
+	Reason    string         `json:"reason"`
+	Frequency string         `json:"frequency"`
+	Schedule  string         `json:"schedule,omitempty"`
+	Group     string         `json:"group,omitempty"`
+	LastSeen  string         `json:"last_seen,omitempty"`
+	Breakdown scoreBreakdown `json:"breakdown"`
+}
+
+// round2 rounds to two decimal places for display.
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// scoreContacts ranks tracked, non-ignored, non-snoozed contacts, highest
+// score first. Ties go to the name that sorts first.
+func scoreContacts(cfg Config, results []clientAndContacts, lastContact map[string]time.Time, now time.Time) []suggestion {
+	today := dateOnly(now)
+	var out []suggestion
+	for _, r := range results {
+		for _, obj := range r.objs {
+			if isIgnored(obj.Card) {
+				continue
+			}
+			d, ok := dueFor(cfg, obj, lastContact, now)
+			if !ok || d.snoozed() {
+				continue
+			}
+			name := contactName(obj)
+
+			s := suggestion{Name: name, Frequency: d.Frequency, Group: getGroup(obj.Card)}
+			if d.Schedule != nil {
+				s.Schedule = d.Schedule.Rule
+			}
+			b := scoreBreakdown{GroupWeight: cfg.groupWeight(s.Group), Overdue: 1, Recency: recencyWeight}
+			if d.Contacted {
+				elapsed := now.Sub(d.Last)
+				s.LastSeen = d.Last.Format("2006-01-02")
+				b.Recency = recencyWeight * math.Min(elapsed.Hours()/recencyHorizon.Hours(), 1)
+			}
+			b.Overdue = d.lateness(now)
+			var reasons []string
+			switch {
+			case !d.Contacted && d.overdue(now):
+				reasons = append(reasons, fmt.Sprintf("never contacted (%s)", d.cadence()))
+			case d.overdue(now):
+				reasons = append(reasons, fmt.Sprintf("overdue: last contact %s ago, %s", formatAgo(now.Sub(d.Last)), d.cadence()))
+			case d.dueSoon(now):
+				reasons = append(reasons, fmt.Sprintf("due soon: last contact %s ago, %s", formatAgo(now.Sub(d.Last)), d.cadence()))
+			default:
+				reasons = append(reasons, fmt.Sprintf("%s (%s)", dueIn(daysFrom(now, d.DueFrom)), d.cadence()))
+			}
+
+			for _, e := range cardEvents(obj.Card, name, today) {
+				if e.DaysUntil >= eventHorizonDays {
+					continue
+				}
+				bonus := eventWeight * float64(eventHorizonDays-e.DaysUntil) / eventHorizonDays
+				if bonus > b.Upcoming {
+					b.Upcoming = bonus
+				}
+				reasons = append(reasons, fmt.Sprintf("%s %s", e.describe(), e.when()))
+			}
+			if b.GroupWeight != 1 {
+				reasons = append(reasons, fmt.Sprintf("%s group weighted %gx", s.Group, b.GroupWeight))
+			}
+
+			s.Score = round2(b.score())
+			s.Breakdown = scoreBreakdown{
+				Overdue:     round2(b.Overdue),
+				GroupWeight: b.GroupWeight,
+				Recency:     round2(b.Recency),
+				Upcoming:    round2(b.Upcoming),
+			}
+			s.Reason = strings.Join(reasons, "; ")
+			out = append(out, s)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "next",
+		Short: "Suggest who to reach out to next",
+		Long: `Rank tracked contacts and show the top suggestions, each with the reason.
+
+Contacts are scored by how overdue they are relative to their frequency (two
+weeks late on a weekly contact counts for more than on a monthly one), scaled
+by their group's weight from the "groups" section of config.json, plus a
+little for a long silence and for a birthday or anniversary in the next two
+weeks:
+
+  score = overdue * group_weight + recency + upcoming
+
+Ignored and snoozed contacts are skipped. --json includes the breakdown.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, _ := cmd.Flags().GetInt("count")
+			if n < 1 {
+				return fmt.Errorf("--count must be at least 1")
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			results, err := allContactsMulti(cfg)
+			if err != nil {
+				return err
+			}
+			entries, err := readLog()
+			if err != nil {
+				return err
+			}
+
+			suggestions := scoreContacts(cfg, results, lastContactTime(entries), time.Now())
+			if len(suggestions) > n {
+				suggestions = suggestions[:n]
+			}
+
+			if isJSONMode(cmd) {
+				if suggestions == nil {
+					suggestions = []suggestion{}
+				}
+				return printJSON(cmd, suggestions)
+			}
+
+			if len(suggestions) == 0 {
+				fmt.Println("No tracked contacts. Use 'frm track' or 'frm triage' to start.")
+				return nil
+			}
+			for i, s := range suggestions {
+				if len(suggestions) > 1 {
+					fmt.Printf("%d. ", i+1)
+				}
+				fmt.Printf("%s (score %.2f)\n", s.Name, s.Score)
+				fmt.Printf("   %s\n", s.Reason)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntP("count", "n", 1, "Number of suggestions to show")
+	rootCmd.AddCommand(cmd)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/emersion/go-vcard"
 	"github.com/spf13/cobra"
 )
 
@@ -35,13 +36,19 @@ func init() {
 			name := contactName(*matches[0].obj)
 			dryRun := isDryRun(cmd)
 
+			var conflicts int
 			if !dryRun {
 				ctx := context.Background()
 				for _, m := range matches {
-					setSnoozeUntil(m.obj.Card, t)
-					if _, err := m.client.PutAddressObject(ctx, m.obj.Path, m.obj.Card); err != nil {
+					conflicted, err := m.client.updateContact(ctx, m.obj, func(card vcard.Card) {
+						setSnoozeUntil(card, t)
+					})
+					if err != nil {
 						return fmt.Errorf("updating contact: %w", err)
 					}
+					if conflicted {
+						conflicts++
+					}
 				}
 			}
 
@@ -52,6 +59,9 @@ func init() {
 					"until":    t.Format("2006-01-02"),
 					"accounts": len(matches),
 				}
+				if conflicts > 0 {
+					out["conflicts"] = conflicts
+				}
 				if dryRun {
 					out["dry_run"] = true
 				}
@@ -65,6 +75,9 @@ func init() {
 			} else {
 				fmt.Printf("Snoozed %s until %s\n", name, t.Format("2006-01-02"))
 			}
+			if conflicts > 0 {
+				fmt.Println(conflictNote(name))
+			}
 			return nil
 		},
 	}
@@ -95,16 +108,22 @@ func init() {
 				}
 			}
 
+			var conflicts int
 			if !dryRun && wouldUpdate > 0 {
 				ctx := context.Background()
 				for _, m := range matches {
 					if _, ok := getSnoozeUntil(m.obj.Card); !ok {
 						continue
 					}
-					removeSnoozeUntil(m.obj.Card)
-					if _, err := m.client.PutAddressObject(ctx, m.obj.Path, m.obj.Card); err != nil {
+					conflicted, err := m.client.updateContact(ctx, m.obj, func(card vcard.Card) {
+						removeSnoozeUntil(card)
+					})
+					if err != nil {
 						return fmt.Errorf("updating contact: %w", err)
 					}
+					if conflicted {
+						conflicts++
+					}
 				}
 			}
 
@@ -114,6 +133,9 @@ func init() {
 					"name":     name,
 					"accounts": wouldUpdate,
 				}
+				if conflicts > 0 {
+					out["conflicts"] = conflicts
+				}
 				if dryRun {
 					out["dry_run"] = true
 				}
@@ -133,6 +155,9 @@ func init() {
 			} else {
 				fmt.Printf("Unsnoozed %s\n", name)
 			}
+			if conflicts > 0 {
+				fmt.Println(conflictNote(name))
+			}
 			return nil
 		},
 	})
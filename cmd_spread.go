@@ -7,6 +7,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/emersion/go-vcard"
 	"github.com/spf13/cobra"
 )
 
@@ -46,7 +47,7 @@ Only affects tracked contacts that have never been contacted.`,
 			type candidate struct {
 				name   string
 				freq   string
-				dur    time.Duration
+				every  frequency
 				rIndex int // index into results
 				oIndex int // index into objs
 			}
@@ -65,20 +66,17 @@ Only affects tracked contacts that have never been contacted.`,
 					if name == "" {
 						continue
 					}
-					if _, ok := lastContact[obj.Path]; ok {
+					if _, ok := lastContactFor(lastContact, obj); ok {
 						continue
 					}
-					if _, ok := lastContact[name]; ok {
-						continue
-					}
-					dur, err := parseDuration(freq)
+					r, err := parseFrequencyRange(freq)
 					if err != nil {
 						continue
 					}
 					freqGroups[freq] = append(freqGroups[freq], candidate{
 						name:   name,
 						freq:   freq,
-						dur:    dur,
+						every:  r.From,
 						rIndex: ri,
 						oIndex: oi,
 					})
@@ -113,8 +111,9 @@ Only affects tracked contacts that have never been contacted.`,
 				fmt.Printf("%s (%d contacts, every %s):\n", freq, n, freq)
 
 				for _, c := range group {
-					// Random date between now and the contact's frequency interval
-					dueIn := time.Duration(rand.Int63n(int64(c.dur)))
+					// Random date between now and one frequency from now (the start
+					// of a window)
+					dueIn := time.Duration(rand.Int63n(int64(c.every.after(now).Sub(now))))
 					snoozeDate := now.Add(dueIn)
 					dueInDays := int(dueIn.Hours() / 24)
 
@@ -123,11 +122,16 @@ Only affects tracked contacts that have never been contacted.`,
 					} else {
 						obj := &results[c.rIndex].objs[c.oIndex]
 						client := results[c.rIndex].client
-						setSnoozeUntil(obj.Card, snoozeDate)
-						if _, err := client.PutAddressObject(ctx, obj.Path, obj.Card); err != nil {
+						conflicted, err := client.updateContact(ctx, obj, func(card vcard.Card) {
+							setSnoozeUntil(card, snoozeDate)
+						})
+						if err != nil {
 							return fmt.Errorf("updating %s: %w", c.name, err)
 						}
 						fmt.Printf("  %s → due in %dd (snoozed until %s)\n", c.name, dueInDays, snoozeDate.Format("2006-01-02"))
+						if conflicted {
+							fmt.Printf("    %s\n", conflictNote(c.name))
+						}
 					}
 					total++
 				}
@@ -29,6 +29,7 @@ func init() {
 			}
 			lastContact := lastContactTime(entries)
 
+			now := time.Now()
 			var totalContacts, tracked, ignoredCount, overdueCount int
 			for _, r := range results {
 				for _, obj := range r.objs {
@@ -40,30 +41,26 @@ func init() {
 						ignoredCount++
 						continue
 					}
-					freq := getFrequency(obj.Card)
-					if freq == "" {
+					if !cfg.tracks(obj.Card) {
 						continue
 					}
 					tracked++
-					dur, err := parseDuration(freq)
-					if err != nil {
-						continue
-					}
-					name := contactName(obj)
-					last, ok := lastContact[obj.Path]
-					if !ok {
-						last, ok = lastContact[name]
-					}
-					if !ok || time.Now().Sub(last) > dur {
+					if d, ok := dueFor(cfg, obj, lastContact, now); ok && d.overdue(now) {
 						overdueCount++
 					}
 				}
 			}
 
-			// Count interactions per contact
+			// Count interactions per contact and per channel
 			contactCounts := make(map[string]int)
+			channelCounts := make(map[string]int)
 			for _, e := range entries {
 				contactCounts[e.Contact]++
+				via := e.Via
+				if via == "" {
+					via = "unspecified"
+				}
+				channelCounts[via]++
 			}
 
 			untriaged := totalContacts - tracked - ignoredCount
@@ -87,6 +84,7 @@ func init() {
 					most, least := mostLeastContacted(contactCounts)
 					result["most_contacted"] = most
 					result["least_contacted"] = least
+					result["by_channel"] = channelCounts
 				}
 				return printJSON(cmd, result)
 			}
@@ -103,6 +101,14 @@ func init() {
 				fmt.Printf("Most contacted:  %s (%d)\n", most, contactCounts[most])
 				fmt.Printf("Least contacted: %s (%d)\n", least, contactCounts[least])
 			}
+
+			// Only break down by channel once some entries record one.
+			if channelCounts["unspecified"] < len(entries) {
+				fmt.Println("By channel:")
+				for _, c := range sortedByCount(channelCounts) {
+					fmt.Printf("  %-14s %d\n", c+":", channelCounts[c])
+				}
+			}
 			return nil
 		},
 	})
@@ -125,3 +131,18 @@ func mostLeastContacted(counts map[string]int) (most, least string) {
 	})
 	return sorted[0].name, sorted[len(sorted)-1].name
 }
+
+// sortedByCount returns the keys of counts, most frequent first.
+func sortedByCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-vcard"
+	"github.com/emersion/go-webdav/carddav"
+	"github.com/spf13/cobra"
+)
+
+// minSuggestGaps is how many gaps between interactions a suggestion needs.
+const minSuggestGaps = 3
+
+// suggestCadences are the frequencies suggest-frequency picks from, so
+// suggestions look like something you'd have chosen yourself.
+var suggestCadences = []string{"3d", "1w", "2w", "3w", "1m", "6w", "2m", "3m", "4m", "6m", "9m", "1y"}
+
+// frequencySuggestion is what 'frm suggest-frequency' proposes for a contact.
+type frequencySuggestion struct {
+	Name       string  `json:"name"`
+	Current    string  `json:"current,omitempty"`
+	Suggested  string  `json:"suggested,omitempty"`
+	Change     bool    `json:"change"`
+	Confidence string  `json:"confidence"` // high, medium, low, or none without enough history
+	Gaps       int     `json:"gaps"`
+	MedianDays float64 `json:"median_days,omitempty"`
+	Spread     float64 `json:"spread,omitempty"` // median absolute deviation / median gap
+	Trend      string  `json:"trend,omitempty"`  // steady, shortening, or lengthening
+	Reason     string  `json:"reason"`
+	Applied    bool    `json:"applied,omitempty"`
+	DryRun     bool    `json:"dry_run,omitempty"` // applied, but only as a preview
+}
+
+// interactionGaps returns the days between a contact's interactions, oldest
+// first. Interactions on the same day count once.
+func interactionGaps(entries []LogEntry, name string, objs []carddav.AddressObject) []float64 {
+	paths := make(map[string]bool)
+	for _, obj := range objs {
+		paths[obj.Path] = true
+	}
+	var days []time.Time
+	for _, e := range entries {
+		if (e.Path != "" && paths[e.Path]) || strings.EqualFold(e.Contact, name) {
+			days = append(days, dateOnly(e.Time.Local()))
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	days = slices.Compact(days)
+	var gaps []float64
+	for i := 1; i < len(days); i++ {
+		gaps = append(gaps, float64(daysBetween(days[i-1], days[i])))
+	}
+	return gaps
+}
+
+func median(xs []float64) float64 {
+	s := slices.Clone(xs)
+	slices.Sort(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+// suggestFrequency proposes a frequency from a contact's gaps. current is
+// their X-FRM-FREQUENCY, if any.
+func suggestFrequency(name, current string, gaps []float64) frequencySuggestion {
+	s := frequencySuggestion{Name: name, Current: current, Gaps: len(gaps), Confidence: "none"}
+	if len(gaps) < minSuggestGaps {
+		s.Reason = fmt.Sprintf("not enough history (%d interactions, need %d)", len(gaps)+1, minSuggestGaps+1)
+		return s
+	}
+
+	med := median(gaps)
+	var dev []float64
+	for _, g := range gaps {
+		dev = append(dev, math.Abs(g-med))
+	}
+	s.MedianDays = med
+	s.Spread = round2(median(dev) / math.Max(med, 1))
+
+	// Compare the recent half of the gaps with the older half. When the
+	// rhythm has clearly changed, the recent half is the better guide.
+	basis := med
+	s.Trend = "steady"
+	if len(gaps) >= 4 {
+		older, recent := median(gaps[:len(gaps)/2]), median(gaps[len(gaps)/2:])
+		switch ratio := recent / math.Max(older, 1); {
+		case ratio < 0.75:
+			s.Trend, basis = "shortening", recent
+		case ratio > 1.33:
+			s.Trend, basis = "lengthening", recent
+		}
+	}
+
+	// The nearest cadence on a log scale, so 10 days is closer to 1w than
+	// to 2w.
+	best := math.Inf(1)
+	for _, c := range suggestCadences {
+		f, _ := parseFrequency(c)
+		if d := math.Abs(math.Log(f.nominal().Hours() / 24 / math.Max(basis, 1))); d < best {
+			best, s.Suggested = d, c
+		}
+	}
+
+	switch {
+	case len(gaps) >= 6 && s.Spread <= 0.35 && s.Trend == "steady":
+		s.Confidence = "high"
+	case len(gaps) >= 4 && s.Spread <= 0.6:
+		s.Confidence = "medium"
+	default:
+		s.Confidence = "low"
+	}
+
+	s.Reason = fmt.Sprintf("median gap %s over %d gaps", formatDays(basis), len(gaps))
+	if s.Trend != "steady" {
+		s.Reason = fmt.Sprintf("recent gaps %s, %s (median %s over %d gaps)", s.Trend, formatDays(basis), formatDays(med), len(gaps))
+	}
+	s.Change = true
+	if r, err := parseFrequencyRange(current); current != "" && err == nil {
+		// Keep a frequency (or window) that already fits the gaps, give or
+		// take 15% either way: up to 1.15 times shorter or longer.
+		from, by := r.From.nominal().Hours()/24, r.By.nominal().Hours()/24
+		fits := basis >= from/1.15 && basis <= by*1.15
+		if fits {
+			s.Suggested = current
+		}
+		// Just outside that band, or past either end of suggestCadences, the
+		// nearest cadence can still be the current one: nothing to change.
+		if s.Suggested == current {
+			s.Change = false
+		}
+		switch {
+		case fits:
+		case basis > by:
+			s.Reason += fmt.Sprintf("; usually later than every %s", current)
+		default:
+			s.Reason += fmt.Sprintf("; usually sooner than every %s", current)
+		}
+	}
+	return s
+}
+
+// formatDays formats a number of days, e.g. "24 days".
+func formatDays(days float64) string {
+	if math.Round(days) == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%.0f days", days)
+}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "suggest-frequency [name]",
+		Short: "Suggest frequencies from how often you actually talk",
+		Long: `Look at the gaps between a contact's logged interactions and propose a
+frequency that matches them, with a confidence level. Pass a name, or --all
+for every contact tracked by frequency.
+
+The suggestion is the usual cadence nearest the median gap, or the median of
+the more recent gaps when they have clearly grown shorter or longer. The
+current frequency is kept when it already fits. Confidence is high with at
+least six steady, consistent gaps, medium with four or more reasonably
+consistent ones, and low otherwise; at least three gaps are needed.
+
+--apply sets each changed frequency the way 'frm track' does; with
+--dry-run it reports what it would change. With --all, low-confidence
+suggestions are shown but not applied.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			all, _ := cmd.Flags().GetBool("all")
+			apply, _ := cmd.Flags().GetBool("apply")
+			if all == (len(args) == 1) {
+				return fmt.Errorf("give a contact name or --all")
+			}
+			dryRun := isDryRun(cmd)
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			entries, err := readLog()
+			if err != nil {
+				return err
+			}
+
+			// Contacts in several accounts are suggested once and updated
+			// in each.
+			type target struct {
+				name    string
+				current string
+				matches []contactMatch
+			}
+			var targets []*target
+			if all {
+				results, err := allContactsMulti(cfg)
+				if err != nil {
+					return err
+				}
+				byName := make(map[string]*target)
+				for ri := range results {
+					for oi := range results[ri].objs {
+						obj := &results[ri].objs[oi]
+						freq := getFrequency(obj.Card)
+						if freq == "" || isIgnored(obj.Card) {
+							continue
+						}
+						name := contactName(*obj)
+						t, ok := byName[name]
+						if !ok {
+							t = &target{name: name, current: freq}
+							byName[name] = t
+							targets = append(targets, t)
+						}
+						t.matches = append(t.matches, contactMatch{obj: obj, client: results[ri].client})
+					}
+				}
+				sort.Slice(targets, func(i, j int) bool { return targets[i].name < targets[j].name })
+			} else {
+				matches, err := findAllContactsMulti(cfg, args[0])
+				if err != nil {
+					return err
+				}
+				obj := matches[0].obj
+				if rule, _ := getSchedule(obj.Card); rule != "" {
+					return fmt.Errorf("%s is on a fixed schedule (%s); use 'frm track --every' to switch to a frequency", contactName(*obj), rule)
+				}
+				targets = append(targets, &target{name: contactName(*obj), current: getFrequency(obj.Card), matches: matches})
+			}
+
+			// With --all, only suggestions worth trusting are applied.
+			applies := func(s frequencySuggestion) bool {
+				return s.Change && s.Suggested != "" && (!all || s.Confidence != "low")
+			}
+			ctx := context.Background()
+			suggestions := []frequencySuggestion{}
+			var conflicts []string
+			for _, t := range targets {
+				objs := make([]carddav.AddressObject, len(t.matches))
+				for i, m := range t.matches {
+					objs[i] = *m.obj
+				}
+				s := suggestFrequency(t.name, t.current, interactionGaps(entries, t.name, objs))
+				if apply && applies(s) && dryRun {
+					s.Applied, s.DryRun = true, true
+				} else if apply && applies(s) {
+					for _, m := range t.matches {
+						conflicted, err := m.client.updateContact(ctx, m.obj, func(card vcard.Card) {
+							trackEvery(card, s.Suggested)
+						})
+						if err != nil {
+							return fmt.Errorf("updating %s: %w", t.name, err)
+						}
+						if conflicted {
+							conflicts = append(conflicts, t.name)
+						}
+					}
+					s.Applied = true
+				}
+				suggestions = append(suggestions, s)
+			}
+
+			if isJSONMode(cmd) {
+				return printJSON(cmd, suggestions)
+			}
+
+			if len(suggestions) == 0 {
+				fmt.Println("No contacts tracked by frequency. Use 'frm track' or 'frm triage' to start.")
+				return nil
+			}
+			var changes, applied, thin int
+			for _, s := range suggestions {
+				switch {
+				case s.Suggested == "":
+					thin++
+					if !all {
+						fmt.Printf("%s: %s\n", s.Name, s.Reason)
+					}
+					continue
+				case !s.Change:
+					fmt.Printf("%s: keep every %s (%s confidence)\n", s.Name, s.Current, s.Confidence)
+				case s.Current == "":
+					fmt.Printf("%s: track every %s (%s confidence)\n", s.Name, s.Suggested, s.Confidence)
+				default:
+					fmt.Printf("%s: every %s → %s (%s confidence)\n", s.Name, s.Current, s.Suggested, s.Confidence)
+				}
+				fmt.Printf("  %s\n", s.Reason)
+				if applies(s) {
+					changes++
+				}
+				if s.Applied {
+					applied++
+				}
+			}
+			for _, name := range slices.Compact(conflicts) {
+				fmt.Println(conflictNote(name))
+			}
+			if all && thin > 0 {
+				fmt.Printf("\nNot enough history to suggest for yet: %d\n", thin)
+			}
+			switch {
+			case applied > 0 && dryRun:
+				fmt.Printf("\nWould update %d frequencies (dry run)\n", applied)
+			case applied > 0:
+				fmt.Printf("\nFrequencies updated: %d\n", applied)
+			case changes > 0 && !apply:
+				fmt.Printf("\nFrequencies to update: %d (run with --apply)\n", changes)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool("all", false, "Suggest for every contact tracked by frequency")
+	cmd.Flags().Bool("apply", false, "Set the suggested frequencies")
+	rootCmd.AddCommand(cmd)
+}
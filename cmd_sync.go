@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+type syncResult struct {
+	Account  string `json:"account"`
+	Contacts int    `json:"contacts"`
+	syncStats
+}
+
+func init() {
+	syncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Refresh the local contact cache from CardDAV",
+		Long: `Contacts are cached under the config directory and refreshed
+incrementally on every command (via sync-collection, or CTag/ETag
+comparison on servers without it). Use this to refresh explicitly,
+e.g. before going offline, or --full to discard the cache and re-download.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if offline {
+				return fmt.Errorf("cannot sync with --offline")
+			}
+			full, _ := cmd.Flags().GetBool("full")
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			svcs := cfg.carddavServices()
+			var results []syncResult
+			var errs []error
+			for _, svc := range svcs {
+				r, err := syncAccount(ctx, svc, full)
+				if err != nil {
+					recordAccountError(svc, err)
+					errs = append(errs, fmt.Errorf("syncing %s: %w", svc.label(), err))
+					continue
+				}
+				results = append(results, r)
+			}
+			if len(errs) > 0 && (strict || len(errs) == len(svcs)) {
+				accountErrorsReported = true
+				return errors.Join(errs...)
+			}
+
+			if isJSONMode(cmd) {
+				return printJSON(cmd, results)
+			}
+			for _, r := range results {
+				fmt.Printf("Synced %s: %d contacts (%d updated, %d removed)\n", r.Account, r.Contacts, r.Updated, r.Deleted)
+			}
+			return nil
+		},
+	}
+	syncCmd.Flags().Bool("full", false, "Discard the cache and download every contact again")
+	rootCmd.AddCommand(syncCmd)
+}
+
+func syncAccount(ctx context.Context, svc ServiceConfig, full bool) (syncResult, error) {
+	client, err := newCardDAVClient(svc)
+	if err != nil {
+		return syncResult{}, err
+	}
+	cache := loadContactCache(svc)
+	if full {
+		cache.reset()
+	}
+	stats, err := cache.sync(ctx, client)
+	if err != nil {
+		return syncResult{}, err
+	}
+	return syncResult{Account: svc.label(), Contacts: len(cache.Objects), syncStats: stats}, nil
+}
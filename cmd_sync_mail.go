@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultAutologWindow is how far back the first sync-mail or sync-meetings
+// run looks.
+const defaultAutologWindow = 30 * 24 * time.Hour
+
+// syncState records, per account, the newest item an autolog command
+// (sync-mail, sync-meetings) has already scanned.
+type syncState map[string]time.Time
+
+func mailSyncStatePath() string {
+	return filepath.Join(configDir(), "mailsync.json")
+}
+
+func loadSyncState(path string) (syncState, error) {
+	state := syncState{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return state, nil
+}
+
+func (s syncState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling sync state: %w", err)
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// emailContact is a contact an email or meeting can be logged against.
+type emailContact struct {
+	name, path string
+}
+
+// contactsByEmail indexes non-ignored contacts by their email addresses. If
+// two contacts share an address, the first one wins.
+func contactsByEmail(results []clientAndContacts) map[string]emailContact {
+	contacts := make(map[string]emailContact)
+	for _, r := range results {
+		for _, obj := range r.objs {
+			if isIgnored(obj.Card) {
+				continue
+			}
+			for _, addr := range cardEmails(obj.Card) {
+				if _, ok := contacts[addr]; !ok {
+					contacts[addr] = emailContact{name: contactName(obj), path: obj.Path}
+				}
+			}
+		}
+	}
+	return contacts
+}
+
+// autologStart picks where an autolog run begins for an account: --since if
+// given, else the stored high-water mark, else defaultAutologWindow ago.
+func autologStart(since time.Time, state syncState, key string) time.Time {
+	if !since.IsZero() {
+		return since
+	}
+	if t := state[key]; !t.IsZero() {
+		return t
+	}
+	return time.Now().UTC().Add(-defaultAutologWindow)
+}
+
+// commitAutolog appends new entries and then saves the high-water marks, so a
+// crash in between only means re-scanning items already logged.
+func commitAutolog(entries []LogEntry, state syncState, statePath string) error {
+	for _, e := range entries {
+		if err := appendLog(e); err != nil {
+			return err
+		}
+	}
+	return state.save(statePath)
+}
+
+// printAutolog reports the result of sync-mail or sync-meetings.
+func printAutolog(cmd *cobra.Command, action, noun, scannedNoun string, logged []LogEntry, scanned int) error {
+	dryRun := isDryRun(cmd)
+	if isJSONMode(cmd) {
+		if logged == nil {
+			logged = []LogEntry{}
+		}
+		out := map[string]interface{}{
+			"action":  action,
+			"scanned": scanned,
+			"logged":  logged,
+		}
+		if dryRun {
+			out["dry_run"] = true
+		}
+		return printJSON(cmd, out)
+	}
+
+	for _, e := range logged {
+		fmt.Printf("  %s  %s  (%s)  %s\n", e.Time.Format("2006-01-02"), e.Contact, e.details(), e.Note)
+	}
+	if dryRun {
+		fmt.Printf("Would log %d %s from %d %s scanned (dry run)\n", len(logged), noun, scanned, scannedNoun)
+	} else {
+		fmt.Printf("Logged %d %s from %d %s scanned\n", len(logged), noun, scanned, scannedNoun)
+	}
+	return nil
+}
+
+// autologKey identifies the entry for one message or meeting and one contact,
+// so it is never logged twice against the same person.
+func autologKey(sourceID, contact, path string) string {
+	if path == "" {
+		path = strings.ToLower(contact)
+	}
+	return sourceID + "\x00" + path
+}
+
+// mailEntries turns messages into log entries, one per contact a message was
+// exchanged with. Mail sent from one of the own addresses is outgoing to each
+// contact among its recipients; mail from a contact is incoming from them.
+// Other mail, and entries whose key is in seen, are skipped.
+func mailEntries(msgs []mailMessage, own map[string]bool, contacts map[string]emailContact, seen map[string]bool) []LogEntry {
+	var entries []LogEntry
+	add := func(msg mailMessage, c emailContact, direction string) {
+		key := autologKey(msg.ID, c.name, c.path)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		note := msg.Subject
+		if note == "" {
+			note = "(no subject)"
+		}
+		entries = append(entries, LogEntry{
+			ID:        newLogID(),
+			Contact:   c.name,
+			Path:      c.path,
+			Time:      msg.Time,
+			Note:      note,
+			Via:       "email",
+			Direction: direction,
+			MessageID: msg.ID,
+		})
+	}
+	for _, msg := range msgs {
+		if own[msg.From] {
+			for _, to := range msg.To {
+				if c, ok := contacts[to]; ok {
+					add(msg, c, "out")
+				}
+			}
+			continue
+		}
+		if c, ok := contacts[msg.From]; ok {
+			add(msg, c, "in")
+		}
+	}
+	return entries
+}
+
+// parseSince parses a start date that is either absolute (2026-01-01) or a
+// duration back from now (30d, 2m).
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", strings.TrimSpace(s)); err == nil {
+		return t.UTC(), nil
+	}
+	f, err := parseFrequency(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or relative like 30d", s)
+	}
+	return f.before(time.Now().UTC()), nil
+}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "sync-mail",
+		Short: "Log emails exchanged with contacts from JMAP",
+		Long: `Scan mail received since the last run on each JMAP account and log an
+interaction (via email, with the subject as the note) for every contact a
+message was exchanged with: mail from a contact is logged as incoming, mail
+you sent to a contact as outgoing. Contacts are matched by their EMAIL
+addresses; ignored contacts are skipped.
+
+The newest message scanned is remembered per account in mailsync.json, and
+entries are de-duplicated by Message-ID, so it is safe to run repeatedly
+(e.g. from cron). The first run looks back 30 days; use --since to change
+where a run starts.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var since time.Time
+			if s, _ := cmd.Flags().GetString("since"); s != "" {
+				t, err := parseSince(s)
+				if err != nil {
+					return err
+				}
+				since = t
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			svcs := cfg.jmapServices()
+			if len(svcs) == 0 {
+				return fmt.Errorf("no jmap service configured; sync-mail needs one")
+			}
+
+			results, err := allContactsMulti(cfg)
+			if err != nil {
+				return err
+			}
+			contacts := contactsByEmail(results)
+
+			existing, err := readLog()
+			if err != nil {
+				return err
+			}
+			seen := make(map[string]bool)
+			for _, e := range existing {
+				if e.MessageID != "" {
+					seen[autologKey(e.MessageID, e.Contact, e.Path)] = true
+				}
+			}
+
+			state, err := loadSyncState(mailSyncStatePath())
+			if err != nil {
+				return err
+			}
+
+			var logged []LogEntry
+			var errs []error
+			scanned := 0
+			for _, svc := range svcs {
+				key := "jmap:" + svc.label()
+				entries, newest, n, err := syncMailAccount(svc, autologStart(since, state, key), contacts, seen)
+				if err != nil {
+					recordAccountError(svc, err)
+					errs = append(errs, fmt.Errorf("%s: %w", svc.label(), err))
+					continue
+				}
+				scanned += n
+				logged = append(logged, entries...)
+				if newest.After(state[key]) {
+					state[key] = newest
+				}
+			}
+			if len(errs) > 0 && (strict || len(errs) == len(svcs)) {
+				accountErrorsReported = true
+				return errors.Join(errs...)
+			}
+
+			if !isDryRun(cmd) {
+				if err := commitAutolog(logged, state, mailSyncStatePath()); err != nil {
+					return err
+				}
+			}
+			return printAutolog(cmd, "sync-mail", "emails", "messages", logged, scanned)
+		},
+	}
+	cmd.Flags().String("since", "", "Scan mail from this date (YYYY-MM-DD) or this far back (e.g. 30d) instead of since the last run")
+	rootCmd.AddCommand(cmd)
+}
+
+// syncMailAccount scans one JMAP account from start, returning the new
+// entries, the newest message time seen and how many messages were scanned.
+func syncMailAccount(svc ServiceConfig, start time.Time, contacts map[string]emailContact, seen map[string]bool) ([]LogEntry, time.Time, int, error) {
+	p, err := newJMAPProvider(svc)
+	if err != nil {
+		return nil, time.Time{}, 0, err
+	}
+	addrs, err := p.ownAddresses()
+	if err != nil {
+		return nil, time.Time{}, 0, err
+	}
+	if len(addrs) == 0 {
+		fmt.Fprintf(os.Stderr, "warning: can't tell which addresses are yours on %s; only incoming mail will be logged\n", svc.label())
+	}
+	own := make(map[string]bool, len(addrs))
+	for _, a := range addrs {
+		own[a] = true
+	}
+
+	msgs, err := p.messagesSince(start)
+	if err != nil {
+		return nil, time.Time{}, 0, err
+	}
+	var newest time.Time
+	for _, m := range msgs {
+		if m.Time.After(newest) {
+			newest = m.Time
+		}
+	}
+	return mailEntries(msgs, own, contacts, seen), newest, len(msgs), nil
+}
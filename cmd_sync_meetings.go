@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func meetingSyncStatePath() string {
+	return filepath.Join(configDir(), "meetingsync.json")
+}
+
+// eventID identifies one occurrence of an event; occurrences of a recurring
+// event share a UID, so the start time is part of it.
+func eventID(e calEvent) string {
+	uid := e.UID
+	if uid == "" {
+		uid = e.Summary
+	}
+	return uid + "@" + e.Start.UTC().Format(time.RFC3339)
+}
+
+// meetingEntries turns events that have ended by now into log entries, one
+// per contact who organized or attended without declining. Entries whose key
+// is in seen are skipped.
+func meetingEntries(events []calEvent, now time.Time, contacts map[string]emailContact, seen map[string]bool) []LogEntry {
+	var entries []LogEntry
+	for _, e := range events {
+		if e.End.After(now) {
+			continue
+		}
+		addrs := []string{e.Organizer}
+		for addr, partstat := range e.Attendees {
+			if partstat != "DECLINED" {
+				addrs = append(addrs, addr)
+			}
+		}
+		sort.Strings(addrs[1:])
+
+		id := eventID(e)
+		for _, addr := range addrs {
+			c, ok := contacts[addr]
+			if !ok {
+				continue
+			}
+			key := autologKey(id, c.name, c.path)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			note := e.Summary
+			if note == "" {
+				note = "(no title)"
+			}
+			entry := LogEntry{
+				ID:       newLogID(),
+				Contact:  c.name,
+				Path:     c.path,
+				Time:     e.Start.UTC(),
+				Note:     note,
+				Via:      "meeting",
+				Location: e.Location,
+				EventID:  id,
+			}
+			if !e.AllDay {
+				entry.DurationMin = int(e.End.Sub(e.Start).Minutes())
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "sync-meetings",
+		Short: "Log past meetings with contacts from CalDAV",
+		Long: `Scan events that ended since the last run on each CalDAV account and log
+an interaction (via meeting, with the event title as the note) for every
+contact who organized or attended it, matched by their EMAIL addresses.
+Attendees who declined and ignored contacts are skipped.
+
+The newest event scanned is remembered per account in meetingsync.json, and
+each occurrence is logged at most once per contact, so it is safe to run
+repeatedly. The first run looks back 30 days; use --since to change where a
+run starts.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var since time.Time
+			if s, _ := cmd.Flags().GetString("since"); s != "" {
+				t, err := parseSince(s)
+				if err != nil {
+					return err
+				}
+				since = t
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			svcs := cfg.caldavServices()
+			if len(svcs) == 0 {
+				return fmt.Errorf("no caldav service configured; sync-meetings needs one")
+			}
+
+			results, err := allContactsMulti(cfg)
+			if err != nil {
+				return err
+			}
+			contacts := contactsByEmail(results)
+
+			existing, err := readLog()
+			if err != nil {
+				return err
+			}
+			seen := make(map[string]bool)
+			for _, e := range existing {
+				if e.EventID != "" {
+					seen[autologKey(e.EventID, e.Contact, e.Path)] = true
+				}
+			}
+
+			state, err := loadSyncState(meetingSyncStatePath())
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			var logged []LogEntry
+			var errs []error
+			scanned := 0
+			for _, svc := range svcs {
+				key := "caldav:" + svc.label()
+				client, err := newCalDAVClient(svc)
+				var events []calEvent
+				if err == nil {
+					events, err = client.events(autologStart(since, state, key), now)
+				}
+				if err != nil {
+					recordAccountError(svc, err)
+					errs = append(errs, fmt.Errorf("%s: %w", svc.label(), err))
+					continue
+				}
+
+				for _, e := range events {
+					if e.End.After(now) {
+						continue
+					}
+					scanned++
+					if e.Start.After(state[key]) {
+						state[key] = e.Start.UTC()
+					}
+				}
+				logged = append(logged, meetingEntries(events, now, contacts, seen)...)
+			}
+			if len(errs) > 0 && (strict || len(errs) == len(svcs)) {
+				accountErrorsReported = true
+				return errors.Join(errs...)
+			}
+
+			if !isDryRun(cmd) {
+				if err := commitAutolog(logged, state, meetingSyncStatePath()); err != nil {
+					return err
+				}
+			}
+			return printAutolog(cmd, "sync-meetings", "meetings", "events", logged, scanned)
+		},
+	}
+	cmd.Flags().String("since", "", "Scan events from this date (YYYY-MM-DD) or this far back (e.g. 30d) instead of since the last run")
+	rootCmd.AddCommand(cmd)
+}
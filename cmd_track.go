@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/emersion/go-vcard"
 	"github.com/spf13/cobra"
 )
 
@@ -11,14 +13,39 @@ func init() {
 	trackCmd := &cobra.Command{
 		Use:   "track <name>",
 		Short: "Set contact frequency (e.g. --every 2w)",
-		Args:  cobra.ExactArgs(1),
+		Long: `Track a contact every so often since you last talked (--every 2w), or on a
+fixed schedule given as an RFC 5545 RRULE (--rrule "FREQ=WEEKLY;BYDAY=SU").
+A scheduled contact is due on each occurrence from today on, and stays due
+until an interaction is logged on or after it.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			every, _ := cmd.Flags().GetString("every")
-			if every == "" {
-				return fmt.Errorf("--every flag is required (e.g. 2w, 1m, 3d)")
+			rrule, _ := cmd.Flags().GetString("rrule")
+			if every != "" && rrule != "" {
+				return fmt.Errorf("use either --every or --rrule, not both")
 			}
-			if _, err := parseDuration(every); err != nil {
-				return err
+			if every == "" && rrule == "" {
+				return fmt.Errorf("--every flag is required (e.g. 2w, 1m, 3d), or --rrule for a fixed schedule")
+			}
+			// A schedule starts today, so only future occurrences count.
+			start := dateOnly(time.Now())
+			var desc string
+			if rrule != "" {
+				s, err := parseSchedule(rrule, start)
+				if err != nil {
+					return err
+				}
+				if _, ok := s.next(start.AddDate(0, 0, -1)); !ok {
+					return fmt.Errorf("schedule %q has no occurrences from today", s.Rule)
+				}
+				rrule, desc = s.Rule, s.String()
+			} else {
+				f, err := parseFrequencyRange(every)
+				if err != nil {
+					return err
+				}
+				every = f.String()
+				desc = "every " + every
 			}
 
 			cfg, err := loadConfig()
@@ -33,22 +60,40 @@ func init() {
 			name := contactName(*matches[0].obj)
 			dryRun := isDryRun(cmd)
 
+			var conflicts int
 			if !dryRun {
 				ctx := context.Background()
 				for _, m := range matches {
-					setFrequency(m.obj.Card, every)
-					if _, err := m.client.PutAddressObject(ctx, m.obj.Path, m.obj.Card); err != nil {
+					conflicted, err := m.client.updateContact(ctx, m.obj, func(card vcard.Card) {
+						if rrule != "" {
+							setSchedule(card, rrule, start)
+							removeFrequency(card)
+						} else {
+							trackEvery(card, every)
+						}
+					})
+					if err != nil {
 						return fmt.Errorf("updating contact: %w", err)
 					}
+					if conflicted {
+						conflicts++
+					}
 				}
 			}
 
 			if isJSONMode(cmd) {
 				out := map[string]interface{}{
-					"action":    "track",
-					"name":      name,
-					"frequency": every,
-					"accounts":  len(matches),
+					"action":   "track",
+					"name":     name,
+					"accounts": len(matches),
+				}
+				if rrule != "" {
+					out["schedule"] = rrule
+				} else {
+					out["frequency"] = every
+				}
+				if conflicts > 0 {
+					out["conflicts"] = conflicts
 				}
 				if dryRun {
 					out["dry_run"] = true
@@ -57,16 +102,20 @@ func init() {
 			}
 
 			if dryRun {
-				fmt.Printf("Would track %s every %s (dry run)\n", name, every)
+				fmt.Printf("Would track %s %s (dry run)\n", name, desc)
 			} else if len(matches) > 1 {
-				fmt.Printf("Tracking %s every %s (%d accounts)\n", name, every, len(matches))
+				fmt.Printf("Tracking %s %s (%d accounts)\n", name, desc, len(matches))
 			} else {
-				fmt.Printf("Tracking %s every %s\n", name, every)
+				fmt.Printf("Tracking %s %s\n", name, desc)
+			}
+			if conflicts > 0 {
+				fmt.Println(conflictNote(name))
 			}
 			return nil
 		},
 	}
-	trackCmd.Flags().String("every", "", "Contact frequency (e.g. 2w, 1m, 3d)")
+	trackCmd.Flags().String("every", "", "Contact frequency (e.g. 2w, 1m2w, 1y, quarterly)")
+	trackCmd.Flags().String("rrule", "", "Fixed schedule as an RFC 5545 RRULE (e.g. \"FREQ=WEEKLY;BYDAY=SU\")")
 
 	untrackCmd := &cobra.Command{
 		Use:   "untrack <name>",
@@ -85,13 +134,20 @@ func init() {
 			name := contactName(*matches[0].obj)
 			dryRun := isDryRun(cmd)
 
+			var conflicts int
 			if !dryRun {
 				ctx := context.Background()
 				for _, m := range matches {
-					removeFrequency(m.obj.Card)
-					if _, err := m.client.PutAddressObject(ctx, m.obj.Path, m.obj.Card); err != nil {
+					conflicted, err := m.client.updateContact(ctx, m.obj, func(card vcard.Card) {
+						removeFrequency(card)
+						removeSchedule(card)
+					})
+					if err != nil {
 						return fmt.Errorf("updating contact: %w", err)
 					}
+					if conflicted {
+						conflicts++
+					}
 				}
 			}
 
@@ -101,6 +157,9 @@ func init() {
 					"name":     name,
 					"accounts": len(matches),
 				}
+				if conflicts > 0 {
+					out["conflicts"] = conflicts
+				}
 				if dryRun {
 					out["dry_run"] = true
 				}
@@ -114,6 +173,9 @@ func init() {
 			} else {
 				fmt.Printf("Stopped tracking %s\n", name)
 			}
+			if conflicts > 0 {
+				fmt.Println(conflictNote(name))
+			}
 			return nil
 		},
 	}
@@ -121,3 +183,9 @@ func init() {
 	rootCmd.AddCommand(trackCmd)
 	rootCmd.AddCommand(untrackCmd)
 }
+
+// trackEvery tracks a contact at a frequency, replacing any schedule.
+func trackEvery(card vcard.Card, every string) {
+	setFrequency(card, every)
+	removeSchedule(card)
+}
@@ -15,7 +15,7 @@ import (
 
 type triageContact struct {
 	obj    carddav.AddressObject
-	client *carddav.Client
+	client *davClient
 }
 
 func init() {
@@ -32,11 +32,11 @@ func init() {
 				return err
 			}
 
-			// Filter to untriaged contacts (no frequency, not ignored)
+			// Filter to untriaged contacts (not tracked, not ignored)
 			var untriaged []triageContact
 			for _, r := range results {
 				for _, obj := range r.objs {
-					if getFrequency(obj.Card) == "" && !isIgnored(obj.Card) {
+					if !cfg.tracks(obj.Card) && !isIgnored(obj.Card) {
 						if contactName(obj) != "" {
 							untriaged = append(untriaged, triageContact{obj: obj, client: r.client})
 						}
@@ -58,8 +58,10 @@ func init() {
 			jsonFlag, _ := cmd.Flags().GetBool("json")
 			if jsonFlag {
 				providers := initProviders(cfg)
+				defer providers.close()
+				contexts := collectContextAll(providers, triageObjects(untriaged))
 				out := make([]map[string]any, 0, len(untriaged))
-				for _, tc := range untriaged {
+				for i, tc := range untriaged {
 					entry := map[string]any{
 						"name": contactName(tc.obj),
 					}
@@ -72,8 +74,8 @@ func init() {
 					if tel := tc.obj.Card.PreferredValue(vcard.FieldTelephone); tel != "" {
 						entry["phone"] = tel
 					}
-					if lines := collectContext(providers, tc.obj.Card); len(lines) > 0 {
-						entry["context"] = lines
+					if groups := contexts[i]; len(groups) > 0 {
+						entry["context"] = groups
 					}
 					out = append(out, entry)
 				}
@@ -82,6 +84,7 @@ func init() {
 
 			reader := bufio.NewReader(cmd.InOrStdin())
 			providers := initProviders(cfg)
+			defer providers.close()
 			return runTriage(context.Background(), untriaged, reader, cmd.OutOrStdout(), providers)
 		},
 	}
@@ -89,8 +92,18 @@ func init() {
 	rootCmd.AddCommand(triageCmd)
 }
 
-func runTriage(ctx context.Context, contacts []triageContact, reader *bufio.Reader, w io.Writer, providers []ContextProvider) error {
+// triageObjects returns the cards of the contacts being triaged.
+func triageObjects(contacts []triageContact) []carddav.AddressObject {
+	objs := make([]carddav.AddressObject, len(contacts))
+	for i, tc := range contacts {
+		objs[i] = tc.obj
+	}
+	return objs
+}
+
+func runTriage(ctx context.Context, contacts []triageContact, reader *bufio.Reader, w io.Writer, providers *contextProviders) error {
 	var monthly, quarterly, yearly, skipped, ignored, custom int
+	contexts := collectContextAll(providers, triageObjects(contacts))
 
 	for i, tc := range contacts {
 		if i > 0 {
@@ -108,12 +121,25 @@ func runTriage(ctx context.Context, contacts []triageContact, reader *bufio.Read
 		if tel := tc.obj.Card.PreferredValue(vcard.FieldTelephone); tel != "" {
 			fmt.Fprintf(w, "  %s\n", tel)
 		}
-		if lines := collectContext(providers, tc.obj.Card); len(lines) > 0 {
+		if lines := contextLines(contexts[i]); len(lines) > 0 {
 			for _, line := range lines {
 				fmt.Fprintf(w, "%s\n", line)
 			}
 		}
 
+		// save writes a change to this contact, replaying it on top of any
+		// edit made elsewhere since the card was fetched.
+		save := func(change func(vcard.Card)) error {
+			conflicted, err := tc.client.updateContact(ctx, &tc.obj, change)
+			if err != nil {
+				return fmt.Errorf("updating %s: %w", name, err)
+			}
+			if conflicted {
+				fmt.Fprintf(w, "  %s\n", conflictNote(name))
+			}
+			return nil
+		}
+
 		for {
 			fmt.Fprintf(w, "  [m]onthly  [q]uarterly  [y]early  [s]kip  [i]gnore  or frequency (e.g. 2w)  [Enter=skip]> ")
 
@@ -126,40 +152,35 @@ func runTriage(ctx context.Context, contacts []triageContact, reader *bufio.Read
 			handled := true
 			switch choice {
 			case "m":
-				setFrequency(tc.obj.Card, "1m")
-				if _, err := tc.client.PutAddressObject(ctx, tc.obj.Path, tc.obj.Card); err != nil {
-					return fmt.Errorf("updating %s: %w", name, err)
+				if err := save(func(card vcard.Card) { setFrequency(card, "1m") }); err != nil {
+					return err
 				}
 				monthly++
 			case "q":
-				setFrequency(tc.obj.Card, "3m")
-				if _, err := tc.client.PutAddressObject(ctx, tc.obj.Path, tc.obj.Card); err != nil {
-					return fmt.Errorf("updating %s: %w", name, err)
+				if err := save(func(card vcard.Card) { setFrequency(card, "3m") }); err != nil {
+					return err
 				}
 				quarterly++
 			case "y":
-				setFrequency(tc.obj.Card, "12m")
-				if _, err := tc.client.PutAddressObject(ctx, tc.obj.Path, tc.obj.Card); err != nil {
-					return fmt.Errorf("updating %s: %w", name, err)
+				if err := save(func(card vcard.Card) { setFrequency(card, "1y") }); err != nil {
+					return err
 				}
 				yearly++
 			case "i":
-				setIgnored(tc.obj.Card)
-				if _, err := tc.client.PutAddressObject(ctx, tc.obj.Path, tc.obj.Card); err != nil {
-					return fmt.Errorf("updating %s: %w", name, err)
+				if err := save(setIgnored); err != nil {
+					return err
 				}
 				ignored++
 			case "s", "":
 				skipped++
 			default:
 				// Try parsing as a custom frequency duration
-				if _, parseErr := parseDuration(choice); parseErr != nil {
+				if f, parseErr := parseFrequencyRange(choice); parseErr != nil {
 					fmt.Fprintf(w, "  Invalid input %q: %v\n", choice, parseErr)
 					handled = false
 				} else {
-					setFrequency(tc.obj.Card, choice)
-					if _, err := tc.client.PutAddressObject(ctx, tc.obj.Path, tc.obj.Card); err != nil {
-						return fmt.Errorf("updating %s: %w", name, err)
+					if err := save(func(card vcard.Card) { setFrequency(card, f.String()) }); err != nil {
+						return err
 					}
 					custom++
 				}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/emersion/go-vcard"
 	"github.com/spf13/cobra"
 )
 
@@ -32,16 +33,22 @@ func init() {
 				}
 			}
 
+			var conflicts int
 			if !dryRun && wouldUpdate > 0 {
 				ctx := context.Background()
 				for _, m := range matches {
 					if !isIgnored(m.obj.Card) {
 						continue
 					}
-					removeIgnored(m.obj.Card)
-					if _, err := m.client.PutAddressObject(ctx, m.obj.Path, m.obj.Card); err != nil {
+					conflicted, err := m.client.updateContact(ctx, m.obj, func(card vcard.Card) {
+						removeIgnored(card)
+					})
+					if err != nil {
 						return fmt.Errorf("updating contact: %w", err)
 					}
+					if conflicted {
+						conflicts++
+					}
 				}
 			}
 
@@ -51,6 +58,9 @@ func init() {
 					"name":     name,
 					"accounts": wouldUpdate,
 				}
+				if conflicts > 0 {
+					out["conflicts"] = conflicts
+				}
 				if dryRun {
 					out["dry_run"] = true
 				}
@@ -70,6 +80,9 @@ func init() {
 			} else {
 				fmt.Printf("Unignored %s\n", name)
 			}
+			if conflicts > 0 {
+				fmt.Println(conflictNote(name))
+			}
 			return nil
 		},
 	})
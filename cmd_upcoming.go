@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/emersion/go-vcard"
+	"github.com/spf13/cobra"
+)
+
+// upcomingEvent is the next birthday or anniversary of a contact.
+type upcomingEvent struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Date      string `json:"date"`
+	DaysUntil int    `json:"days_until"`
+	Years     int    `json:"years,omitempty"`
+	Original  string `json:"original"`
+}
+
+// describe renders the event like "birthday, turning 41".
+func (e upcomingEvent) describe() string {
+	switch {
+	case e.Years > 0 && e.Kind == "birthday":
+		return fmt.Sprintf("%s, turning %d", e.Kind, e.Years)
+	case e.Years > 0:
+		return fmt.Sprintf("%s, %d years", e.Kind, e.Years)
+	}
+	return e.Kind
+}
+
+// when renders how far off the event is.
+func (e upcomingEvent) when() string {
+	switch e.DaysUntil {
+	case 0:
+		return "today"
+	case 1:
+		return "tomorrow"
+	}
+	return fmt.Sprintf("in %d days", e.DaysUntil)
+}
+
+// cardEvents returns the next birthday and anniversary on a card, counted
+// from today.
+func cardEvents(card vcard.Card, name string, today time.Time) []upcomingEvent {
+	var events []upcomingEvent
+	add := func(kind string, d cardDate, ok bool) {
+		if !ok {
+			return
+		}
+		next := d.next(today)
+		e := upcomingEvent{
+			Name:      name,
+			Kind:      kind,
+			Date:      next.Format("2006-01-02"),
+			DaysUntil: int(next.Sub(today).Hours() / 24),
+			Original:  d.String(),
+		}
+		if d.Year != 0 {
+			e.Years = next.Year() - d.Year
+		}
+		events = append(events, e)
+	}
+	bday, ok := getBirthday(card)
+	add("birthday", bday, ok)
+	anniv, ok := getAnniversary(card)
+	add("anniversary", anniv, ok)
+	return events
+}
+
+// upcomingEvents lists birthdays and anniversaries of non-ignored contacts
+// falling within the given window, soonest first.
+func upcomingEvents(results []clientAndContacts, within time.Duration) []upcomingEvent {
+	today := dateOnly(time.Now())
+	days := int(within.Hours() / 24)
+	events := []upcomingEvent{}
+	for _, r := range results {
+		for _, obj := range r.objs {
+			if isIgnored(obj.Card) {
+				continue
+			}
+			for _, e := range cardEvents(obj.Card, contactName(obj), today) {
+				if e.DaysUntil <= days {
+					events = append(events, e)
+				}
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].DaysUntil != events[j].DaysUntil {
+			return events[i].DaysUntil < events[j].DaysUntil
+		}
+		return events[i].Name < events[j].Name
+	})
+	return events
+}
+
+// printUpcoming prints one line per event.
+func printUpcoming(events []upcomingEvent) {
+	for _, e := range events {
+		date, _ := time.Parse("2006-01-02", e.Date)
+		fmt.Printf("  %s  %s: %s (%s)\n", date.Format("Mon Jan 2"), e.Name, e.describe(), e.when())
+	}
+}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "upcoming",
+		Short: "Show upcoming birthdays and anniversaries",
+		Long: `List birthdays (BDAY) and anniversaries (ANNIVERSARY, or X-ANNIVERSARY on
+vCard 3.0 cards) coming up within a window. Dates without a year (--MM-DD)
+are supported; Feb 29 falls on Feb 28 in non-leap years.
+
+Set them with 'frm edit --birthday' and 'frm edit --anniversary'.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			withinStr, _ := cmd.Flags().GetString("within")
+			within, err := parseDuration(withinStr)
+			if err != nil {
+				return err
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			results, err := allContactsMulti(cfg)
+			if err != nil {
+				return err
+			}
+			events := upcomingEvents(results, within)
+
+			if isJSONMode(cmd) {
+				return printJSON(cmd, events)
+			}
+			if len(events) == 0 {
+				fmt.Printf("No birthdays or anniversaries in the next %s.\n", withinStr)
+				return nil
+			}
+			fmt.Printf("Coming up in the next %s:\n", withinStr)
+			printUpcoming(events)
+			return nil
+		},
+	}
+	cmd.Flags().String("within", "30d", "How far ahead to look (e.g. 2w, 30d, 3m)")
+	rootCmd.AddCommand(cmd)
+}
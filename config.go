@@ -3,17 +3,84 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-vcard"
 )
 
 type Config struct {
 	Services []ServiceConfig `json:"services"`
+	// Groups holds settings for contacts by X-FRM-GROUP value.
+	Groups map[string]GroupConfig `json:"groups,omitempty"`
+}
+
+// GroupConfig holds the settings for one group.
+type GroupConfig struct {
+	// Weight scales how strongly 'frm next' favours the group's contacts;
+	// 0 means the default of 1.
+	Weight float64 `json:"weight,omitempty"`
+	// Frequency is the default for members without their own
+	// X-FRM-FREQUENCY or schedule, e.g. "2w" or "3w-5w".
+	Frequency string `json:"frequency,omitempty"`
+	// SnoozeUntil (YYYY-MM-DD) pauses every member until that date.
+	SnoozeUntil string `json:"snooze_until,omitempty"`
+}
+
+// group returns the settings for a group, matching its name
+// case-insensitively, and the name it's configured under.
+func (cfg Config) group(group string) (GroupConfig, string, bool) {
+	if group == "" {
+		return GroupConfig{}, "", false
+	}
+	if g, ok := cfg.Groups[group]; ok {
+		return g, group, true
+	}
+	for name, g := range cfg.Groups {
+		if strings.EqualFold(name, group) {
+			return g, name, true
+		}
+	}
+	return GroupConfig{}, "", false
+}
+
+// groupWeight returns the 'frm next' weight for a group, matching its name
+// case-insensitively.
+func (cfg Config) groupWeight(group string) float64 {
+	if g, _, ok := cfg.group(group); ok && g.Weight > 0 {
+		return g.Weight
+	}
+	return 1
+}
+
+// groupFrequency returns the default frequency for a group's members, or "".
+func (cfg Config) groupFrequency(group string) string {
+	g, _, _ := cfg.group(group)
+	return g.Frequency
+}
+
+// groupSnoozeUntil returns when a group's snooze ends, if it has one.
+func (cfg Config) groupSnoozeUntil(group string) (time.Time, bool) {
+	g, _, ok := cfg.group(group)
+	if !ok || g.SnoozeUntil == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006-01-02", g.SnoozeUntil)
+	return t, err == nil
+}
+
+// tracks reports whether a contact is tracked, by its own frequency or
+// schedule or by its group's default frequency.
+func (cfg Config) tracks(card vcard.Card) bool {
+	return isTracked(card) || cfg.groupFrequency(getGroup(card)) != ""
 }
 
 type ServiceConfig struct {
 	Type string `json:"type"`
-	// CardDAV fields
+	// CardDAV (and CalDAV) fields
 	Endpoint string `json:"endpoint,omitempty"`
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
@@ -21,6 +88,47 @@ type ServiceConfig struct {
 	SessionEndpoint string `json:"session_endpoint,omitempty"`
 	Token           string `json:"token,omitempty"`
 	MaxResults      int    `json:"max_results,omitempty"`
+	Window          string `json:"window,omitempty"`       // only show mail this recent, e.g. "90d"
+	URLTemplate     string `json:"url_template,omitempty"` // link to a message; {id} and {thread_id} are filled in
+	// IMAP fields (plus username, password and max_results)
+	Host      string   `json:"host,omitempty"`
+	Port      int      `json:"port,omitempty"`
+	TLS       string   `json:"tls,omitempty"` // "tls" (default), "starttls" or "none"
+	Mailboxes []string `json:"mailboxes,omitempty"`
+	// Maildir and mbox fields (plus max_results)
+	Path string `json:"path,omitempty"` // Maildir tree, or an mbox file or directory of them
+	// Exec fields
+	Command []string `json:"command,omitempty"` // program and arguments
+	Name    string   `json:"name,omitempty"`    // heading for its context; defaults to the program name
+	Timeout string   `json:"timeout,omitempty"` // per contact, e.g. "10s"
+	// Context providers: how long results are reused, e.g. "30m" or "0s"
+	// to always look up afresh. Defaults to 1h for JMAP, IMAP and CalDAV.
+	CacheTTL string `json:"cache_ttl,omitempty"`
+}
+
+// label identifies a service in messages, e.g. "you@icloud.com (contacts.icloud.com)".
+func (s ServiceConfig) label() string {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = s.SessionEndpoint
+	}
+	if endpoint == "" {
+		endpoint = s.Host
+	}
+	if endpoint == "" {
+		endpoint = s.Path
+	}
+	if endpoint == "" && len(s.Command) > 0 {
+		endpoint = s.Command[0]
+	}
+	host := endpoint
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	if s.Username == "" {
+		return host
+	}
+	return fmt.Sprintf("%s (%s)", s.Username, host)
 }
 
 func (cfg Config) carddavServices() []ServiceConfig {
@@ -43,6 +151,47 @@ func (cfg Config) jmapServices() []ServiceConfig {
 	return out
 }
 
+func (cfg Config) caldavServices() []ServiceConfig {
+	var out []ServiceConfig
+	for _, s := range cfg.Services {
+		if s.Type == "caldav" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (cfg Config) imapServices() []ServiceConfig {
+	var out []ServiceConfig
+	for _, s := range cfg.Services {
+		if s.Type == "imap" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// localMailServices returns the maildir and mbox services.
+func (cfg Config) localMailServices() []ServiceConfig {
+	var out []ServiceConfig
+	for _, s := range cfg.Services {
+		if s.Type == "maildir" || s.Type == "mbox" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (cfg Config) execServices() []ServiceConfig {
+	var out []ServiceConfig
+	for _, s := range cfg.Services {
+		if s.Type == "exec" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
 func configDir() string {
 	if dir := os.Getenv("FRM_CONFIG_DIR"); dir != "" {
 		return dir
@@ -69,13 +218,13 @@ func loadConfig() (Config, error) {
 		return cfg, fmt.Errorf("invalid config JSON: %w", err)
 	}
 
-	knownTypes := map[string]bool{"carddav": true, "jmap": true}
+	knownTypes := map[string]bool{"carddav": true, "jmap": true, "imap": true, "caldav": true, "maildir": true, "mbox": true, "exec": true}
 	for i, svc := range cfg.Services {
 		if svc.Type == "" {
-			return cfg, fmt.Errorf("service %d has no type (must be \"carddav\" or \"jmap\")", i)
+			return cfg, fmt.Errorf("service %d has no type (must be \"carddav\", \"jmap\", \"imap\", \"caldav\", \"maildir\", \"mbox\" or \"exec\")", i)
 		}
 		if !knownTypes[svc.Type] {
-			fmt.Fprintf(os.Stderr, "WARNING: service %d has unknown type %q (expected \"carddav\" or \"jmap\") — skipping\n", i, svc.Type)
+			fmt.Fprintf(os.Stderr, "WARNING: service %d has unknown type %q (expected \"carddav\", \"jmap\", \"imap\", \"caldav\", \"maildir\", \"mbox\" or \"exec\") — skipping\n", i, svc.Type)
 		}
 	}
 
@@ -92,6 +241,54 @@ func loadConfig() (Config, error) {
 		if svc.SessionEndpoint == "" || svc.Token == "" {
 			return cfg, fmt.Errorf("jmap service %d must include session_endpoint and token", i)
 		}
+		if svc.Window != "" {
+			if _, err := parseDuration(svc.Window); err != nil {
+				return cfg, fmt.Errorf("jmap service %d has invalid window: %w", i, err)
+			}
+		}
+	}
+	for i, svc := range cfg.imapServices() {
+		if svc.Host == "" || svc.Username == "" || svc.Password == "" {
+			return cfg, fmt.Errorf("imap service %d must include host, username, and password", i)
+		}
+	}
+	for i, svc := range cfg.caldavServices() {
+		if svc.Endpoint == "" || svc.Username == "" || svc.Password == "" {
+			return cfg, fmt.Errorf("caldav service %d must include endpoint, username, and password", i)
+		}
+	}
+	for i, svc := range cfg.localMailServices() {
+		if svc.Path == "" {
+			return cfg, fmt.Errorf("%s service %d must include path", svc.Type, i)
+		}
+	}
+	for name, g := range cfg.Groups {
+		if g.Weight < 0 {
+			return cfg, fmt.Errorf("group %q has a negative weight", name)
+		}
+		if g.Frequency != "" {
+			if _, err := parseFrequencyRange(g.Frequency); err != nil {
+				return cfg, fmt.Errorf("group %q: %w", name, err)
+			}
+		}
+		if g.SnoozeUntil != "" {
+			if _, err := time.Parse("2006-01-02", g.SnoozeUntil); err != nil {
+				return cfg, fmt.Errorf("group %q has invalid snooze_until %q: use YYYY-MM-DD", name, g.SnoozeUntil)
+			}
+		}
+	}
+	for i, svc := range cfg.Services {
+		if svc.CacheTTL == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(svc.CacheTTL); err != nil || d < 0 {
+			return cfg, fmt.Errorf("service %d has invalid cache_ttl %q: use a duration like 30m", i, svc.CacheTTL)
+		}
+	}
+	for i, svc := range cfg.execServices() {
+		if len(svc.Command) == 0 || svc.Command[0] == "" {
+			return cfg, fmt.Errorf("exec service %d must include command", i)
+		}
 	}
 	return cfg, nil
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"time"
+
+	"github.com/emersion/go-webdav/carddav"
+)
+
+// lastContactFor looks up when a contact was last contacted, preferring the
+// path (which survives renames) and falling back to the name.
+func lastContactFor(lastContact map[string]time.Time, obj carddav.AddressObject) (time.Time, bool) {
+	if last, ok := lastContact[obj.Path]; ok {
+		return last, true
+	}
+	last, ok := lastContact[contactName(obj)]
+	return last, ok
+}
+
+// contactDue is when a tracked contact is next due.
+type contactDue struct {
+	Frequency    string
+	Every        frequencyRange
+	Schedule     *schedule     // set instead of Frequency for fixed-schedule contacts
+	Interval     time.Duration // nominal length of the cadence, for comparisons
+	Period       time.Duration // length of the current cycle, ending at Due
+	Last         time.Time     // zero if never contacted
+	Contacted    bool
+	DueFrom      time.Time // when a window opens (Every.From after Last); Due otherwise
+	Due          time.Time // one frequency after Last (now if never contacted), or the occurrence it's due on
+	SnoozedUntil time.Time // zero unless snoozed past now
+	FromGroup    bool      // Frequency is the group's default
+	GroupSnoozed bool      // SnoozedUntil is the group's snooze
+}
+
+// dueFor works out when a contact is due, falling back on their group's
+// default frequency and snooze from cfg. It reports false for contacts that
+// aren't tracked, whose frequency or schedule can't be parsed, or whose
+// schedule has ended.
+func dueFor(cfg Config, obj carddav.AddressObject, lastContact map[string]time.Time, now time.Time) (contactDue, bool) {
+	last, contacted := lastContactFor(lastContact, obj)
+	d := contactDue{Last: last, Contacted: contacted}
+	group := getGroup(obj.Card)
+	if until, ok := getSnoozeUntil(obj.Card); ok && now.Before(until) {
+		d.SnoozedUntil = until
+	}
+	if until, ok := cfg.groupSnoozeUntil(group); ok && now.Before(until) && until.After(d.SnoozedUntil) {
+		d.SnoozedUntil, d.GroupSnoozed = until, true
+	}
+
+	if rule, start := getSchedule(obj.Card); rule != "" {
+		s, err := parseSchedule(rule, start)
+		if err != nil {
+			return contactDue{}, false
+		}
+		d.Schedule, d.Interval = s, s.nominal()
+		// Due on the latest occurrence if nothing was logged since, and
+		// otherwise on the next one.
+		today := dateOnly(now)
+		day, ok := s.previous(today)
+		if !ok || (contacted && !last.Before(localMidnight(day))) {
+			if day, ok = s.next(today); !ok {
+				return contactDue{}, false
+			}
+		}
+		d.Due = localMidnight(day)
+		d.DueFrom = d.Due
+		d.Period = d.Interval
+		if before, ok := s.previous(day.AddDate(0, 0, -1)); ok {
+			d.Period = d.Due.Sub(localMidnight(before))
+		}
+		return d, true
+	}
+
+	freq := getFrequency(obj.Card)
+	if freq == "" {
+		if freq = cfg.groupFrequency(group); freq == "" {
+			return contactDue{}, false
+		}
+		d.FromGroup = true
+	}
+	r, err := parseFrequencyRange(freq)
+	if err != nil {
+		return contactDue{}, false
+	}
+	d.Frequency, d.Every, d.Interval, d.Period = freq, r, r.By.nominal(), r.By.nominal()
+	d.DueFrom, d.Due = now, now
+	if contacted {
+		d.DueFrom, d.Due = r.From.after(last), r.By.after(last)
+		d.Period = d.Due.Sub(last)
+	}
+	return d, true
+}
+
+// localMidnight is the start of a day returned by dateOnly, in local time.
+func localMidnight(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// dueInDays is how many calendar days from today the contact is due:
+// negative when overdue, 0 when never contacted.
+func (d contactDue) dueInDays(now time.Time) int {
+	return daysFrom(now, d.Due)
+}
+
+// daysFrom is how many calendar days t is from now's date.
+func daysFrom(now, t time.Time) int {
+	return daysBetween(dateOnly(now), dateOnly(t.Local()))
+}
+
+// cadence describes how often the contact is due, e.g. "every 2w" or
+// "weekly on Sunday".
+func (d contactDue) cadence() string {
+	if d.Schedule != nil {
+		return d.Schedule.String()
+	}
+	return "every " + d.Frequency
+}
+
+// dueSoon reports whether a contact's window has opened but it isn't
+// overdue yet.
+func (d contactDue) dueSoon(now time.Time) bool {
+	return !d.overdue(now) && !now.Before(d.DueFrom) && d.DueFrom.Before(d.Due)
+}
+
+// lateness is how far through its cycle the contact is: 1 when due (or never
+// contacted), more when overdue.
+func (d contactDue) lateness(now time.Time) float64 {
+	if !d.Contacted && d.Schedule == nil {
+		return 1
+	}
+	return 1 + now.Sub(d.Due).Hours()/d.Period.Hours()
+}
+
+// snoozed reports whether the contact is snoozed.
+func (d contactDue) snoozed() bool {
+	return !d.SnoozedUntil.IsZero()
+}
+
+// overdue reports whether more than one interval (the end of a window) has
+// passed since the last contact, or there has been none. A scheduled contact
+// is overdue from an occurrence with nothing logged since.
+func (d contactDue) overdue(now time.Time) bool {
+	if d.Schedule != nil {
+		return !now.Before(d.Due)
+	}
+	return !d.Contacted || now.After(d.Due)
+}
+
+// daysUntil is dueInDays, except that a snooze pushes the date out to its
+// end.
+func (d contactDue) daysUntil(now time.Time) int {
+	if d.snoozed() {
+		return daysBetween(dateOnly(now), d.SnoozedUntil)
+	}
+	return d.dueInDays(now)
+}
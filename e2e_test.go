@@ -4,17 +4,26 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/emersion/go-imap/backend/memory"
+	imapserver "github.com/emersion/go-imap/server"
 	"github.com/emersion/go-vcard"
 	"github.com/emersion/go-webdav"
 	"github.com/emersion/go-webdav/carddav"
@@ -27,6 +36,10 @@ import (
 type memBackend struct {
 	mu       sync.Mutex
 	contacts map[string]carddav.AddressObject // path -> object
+
+	// interfere, if set, edits the stored card right before the next PUT is
+	// applied, simulating a concurrent change from another client.
+	interfere func(card vcard.Card)
 }
 
 func newMemBackend() *memBackend {
@@ -138,6 +151,20 @@ func (b *memBackend) QueryAddressObjects(ctx context.Context, path string, query
 func (b *memBackend) PutAddressObject(ctx context.Context, path string, card vcard.Card, opts *carddav.PutAddressObjectOptions) (*carddav.AddressObject, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	if b.interfere != nil {
+		if cur, ok := b.contacts[path]; ok {
+			b.interfere(cur.Card)
+			cur.ETag = fmt.Sprintf("%d-interfered", time.Now().UnixNano())
+			b.contacts[path] = cur
+		}
+		b.interfere = nil
+	}
+	if opts != nil && opts.IfMatch.IsSet() {
+		cur := b.contacts[path]
+		if ok, _ := opts.IfMatch.MatchETag(cur.ETag); !ok {
+			return nil, webdav.NewHTTPError(http.StatusPreconditionFailed, fmt.Errorf("etag mismatch"))
+		}
+	}
 	obj := carddav.AddressObject{
 		Path:    path,
 		ModTime: time.Now(),
@@ -184,6 +211,8 @@ type testEnv struct {
 	server    *httptest.Server
 	backend   *memBackend
 	configDir string
+
+	jmapRequests *atomic.Int32 // API requests made to the mock JMAP server, if any
 }
 
 func setupTest(t *testing.T) *testEnv {
@@ -238,6 +267,33 @@ func (e *testEnv) runWithStdin(t *testing.T, stdin *strings.Reader, args ...stri
 	return stdout.String(), stderr.String(), err
 }
 
+// addService appends a service to the test config.
+func (e *testEnv) addService(t *testing.T, svc ServiceConfig) {
+	t.Helper()
+	e.updateConfig(t, func(cfg *Config) {
+		cfg.Services = append(cfg.Services, svc)
+	})
+}
+
+// updateConfig applies change to the test's config.json.
+func (e *testEnv) updateConfig(t *testing.T, change func(*Config)) {
+	t.Helper()
+	path := filepath.Join(e.configDir, "config.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("parsing config: %v", err)
+	}
+	change(&cfg)
+	data, _ = json.Marshal(cfg)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+}
+
 func (e *testEnv) getContactCard(name string) vcard.Card {
 	e.backend.mu.Lock()
 	defer e.backend.mu.Unlock()
@@ -372,6 +428,392 @@ func TestE2E_Track(t *testing.T) {
 	}
 }
 
+func TestE2E_FrequencyGrammar(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "")
+	env.backend.seedContact("Bob", "12m") // stored before years existed
+	env.backend.seedContact("Carol", "")
+	env.backend.seedContact("Dave", "")
+
+	for _, tc := range []struct{ name, every, want string }{
+		{"Alice", "yearly", "1y"},
+		{"Carol", "1m2w", "1m2w"},
+		{"Dave", "Quarterly", "3m"},
+	} {
+		stdout, _, err := env.run(t, "track", tc.name, "--every", tc.every)
+		if err != nil {
+			t.Fatalf("frm track %s --every %s failed: %v", tc.name, tc.every, err)
+		}
+		if !strings.Contains(stdout, "every "+tc.want) {
+			t.Errorf("unexpected output: %q", stdout)
+		}
+		if freq := env.getContactCard(tc.name).PreferredValue(fieldFrequency); freq != tc.want {
+			t.Errorf("expected %s stored as %s, got %q", tc.every, tc.want, freq)
+		}
+	}
+	for _, every := range []string{"2x", "0d", "1w1w", "fortnight"} {
+		if _, _, err := env.run(t, "track", "Alice", "--every", every); err == nil {
+			t.Errorf("expected --every %s to fail", every)
+		}
+	}
+
+	// A year and twelve months are calendar years, not 360 days, and 1m2w is
+	// a calendar month plus two weeks.
+	today := dateOnly(time.Now())
+	for _, args := range [][]string{
+		{"log", "Alice", "--when", today.AddDate(-1, 0, 2).Format("2006-01-02")},
+		{"log", "Bob", "--when", today.AddDate(-1, 0, 2).Format("2006-01-02")},
+		{"log", "Carol", "--when", addMonths(today, -1).AddDate(0, 0, -15).Format("2006-01-02")},
+		{"snooze", "Dave", "--until", "quarterly"},
+	} {
+		if _, stderr, err := env.run(t, args...); err != nil {
+			t.Fatalf("frm %v failed: %v\nstderr: %s", args, err, stderr)
+		}
+	}
+	stdout, _, err := env.run(t, "check")
+	if err != nil {
+		t.Fatalf("frm check failed: %v", err)
+	}
+	if strings.Contains(stdout, "Alice") || strings.Contains(stdout, "Bob") {
+		t.Errorf("Alice and Bob aren't due for another day or two, got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "Carol (every 1m2w") {
+		t.Errorf("expected Carol to be overdue, got: %s", stdout)
+	}
+
+	want := addMonths(time.Now(), 3).Format("2006-01-02")
+	if until := env.getContactCard("Dave").PreferredValue(fieldSnoozeUntil); until != want {
+		t.Errorf("expected Dave snoozed until %s, got %q", want, until)
+	}
+}
+
+func TestE2E_TrackSchedule(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Mom", "")
+	env.backend.seedContact("Dad", "2w")
+	env.backend.seedContact("Boss", "")
+
+	days := []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+	now := time.Now()
+	today, tomorrow := now.Weekday(), now.AddDate(0, 0, 1).Weekday()
+
+	stdout, _, err := env.run(t, "track", "Mom", "--rrule", "FREQ=WEEKLY;BYDAY="+days[today])
+	if err != nil {
+		t.Fatalf("frm track --rrule failed: %v", err)
+	}
+	if want := "Tracking Mom weekly on " + today.String(); !strings.Contains(stdout, want) {
+		t.Errorf("expected %q, got: %s", want, stdout)
+	}
+	card := env.getContactCard("Mom")
+	if rule := card.PreferredValue(fieldSchedule); rule != "FREQ=WEEKLY;BYDAY="+days[today] {
+		t.Errorf("expected the rule to be stored, got %q", rule)
+	}
+	if start := card.Preferred(fieldSchedule).Params.Get("DTSTART"); start != now.Format("20060102") {
+		t.Errorf("expected the schedule to start today, got %q", start)
+	}
+	// Switching Dad to a schedule drops his frequency.
+	if _, _, err := env.run(t, "track", "Dad", "--rrule", "freq=weekly;byday="+days[tomorrow]); err != nil {
+		t.Fatalf("frm track --rrule failed: %v", err)
+	}
+	if freq := env.getContactCard("Dad").PreferredValue(fieldFrequency); freq != "" {
+		t.Errorf("expected Dad's frequency to be removed, got %q", freq)
+	}
+	for _, rule := range []string{"FREQ=HOURLY", "BYDAY=MO", "FREQ=WEEKLY;COUNT=3", "FREQ=WEEKLY;BYDAY=1MO", "FREQ=DAILY;UNTIL=20000101"} {
+		if _, _, err := env.run(t, "track", "Boss", "--rrule", rule); err == nil {
+			t.Errorf("expected --rrule %s to fail", rule)
+		}
+	}
+	if _, _, err := env.run(t, "track", "Boss", "--every", "1w", "--rrule", "FREQ=DAILY"); err == nil {
+		t.Error("expected --every with --rrule to fail")
+	}
+
+	// Mom's call is today and hasn't happened; Dad's is tomorrow.
+	stdout, _, err = env.run(t, "check")
+	if err != nil {
+		t.Fatalf("frm check failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Mom (weekly on "+today.String()+", never contacted)") || strings.Contains(stdout, "Dad") {
+		t.Errorf("expected only Mom to be due, got: %s", stdout)
+	}
+	stdout, _, err = env.run(t, "check", "--within", "2d")
+	if err != nil {
+		t.Fatalf("frm check --within failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Dad (weekly on "+tomorrow.String()+", never contacted, due tomorrow)") {
+		t.Errorf("expected Dad to be due tomorrow, got: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "next", "--json")
+	if err != nil {
+		t.Fatalf("frm next failed: %v", err)
+	}
+	var got []suggestion
+	if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout)
+	}
+	if len(got) != 1 || got[0].Name != "Mom" || got[0].Schedule != "FREQ=WEEKLY;BYDAY="+days[today] || got[0].Breakdown.Overdue < 1 {
+		t.Errorf("expected Mom to be suggested on her schedule, got %+v", got)
+	}
+
+	// Once the call is logged, Mom is next due in a week.
+	if _, _, err := env.run(t, "log", "Mom", "--note", "Sunday call"); err != nil {
+		t.Fatalf("frm log failed: %v", err)
+	}
+	stdout, _, err = env.run(t, "check")
+	if err != nil {
+		t.Fatalf("frm check failed: %v", err)
+	}
+	if strings.Contains(stdout, "Mom") {
+		t.Errorf("Mom was just called, got: %s", stdout)
+	}
+	stdout, _, err = env.run(t, "list", "--json")
+	if err != nil {
+		t.Fatalf("frm list failed: %v", err)
+	}
+	var list []map[string]any
+	if err := json.Unmarshal([]byte(stdout), &list); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout)
+	}
+	for _, e := range list {
+		switch e["name"] {
+		case "Mom":
+			if e["due_in_days"] != float64(7) || e["schedule"] == nil {
+				t.Errorf("expected Mom due in 7 days on a schedule, got %v", e)
+			}
+		case "Dad":
+			if e["due_in_days"] != float64(1) {
+				t.Errorf("expected Dad due tomorrow, got %v", e)
+			}
+		}
+	}
+	stdout, _, _ = env.run(t, "list")
+	if !strings.Contains(stdout, "weekly on "+today.String()) {
+		t.Errorf("expected list to describe Mom's schedule, got: %s", stdout)
+	}
+
+	if _, _, err := env.run(t, "untrack", "Mom"); err != nil {
+		t.Fatalf("frm untrack failed: %v", err)
+	}
+	if env.getContactCard("Mom").Preferred(fieldSchedule) != nil {
+		t.Error("expected untrack to remove the schedule")
+	}
+}
+
+func TestE2E_FrequencyWindow(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "3w-5w")
+	env.backend.seedContact("Bob", "3w-5w")
+	env.backend.seedContact("Carol", "3w-5w")
+	env.backend.seedContact("Dave", "")
+
+	today := dateOnly(time.Now())
+	date := func(days int) string { return today.AddDate(0, 0, days).Format("2006-01-02") }
+	for _, args := range [][]string{
+		{"log", "Alice", "--when", date(-28)},
+		{"log", "Bob", "--when", date(-42)},
+		{"log", "Carol", "--when", date(-7)},
+	} {
+		if _, stderr, err := env.run(t, args...); err != nil {
+			t.Fatalf("frm %v failed: %v\nstderr: %s", args, err, stderr)
+		}
+	}
+
+	if _, _, err := env.run(t, "track", "Dave", "--every", "biweekly-1m"); err != nil {
+		t.Fatalf("frm track with a window failed: %v", err)
+	}
+	if freq := env.getContactCard("Dave").PreferredValue(fieldFrequency); freq != "2w-1m" {
+		t.Errorf("expected the window stored as 2w-1m, got %q", freq)
+	}
+	if _, _, err := env.run(t, "track", "Dave", "--every", "5w-3w"); err == nil {
+		t.Error("expected a backwards window to fail")
+	}
+
+	// Alice's window has opened but she isn't overdue until it closes; Bob
+	// is past it.
+	stdout, _, err := env.run(t, "check")
+	if err != nil {
+		t.Fatalf("frm check failed: %v", err)
+	}
+	overdue, soon, ok := strings.Cut(stdout, "Due soon:")
+	if !ok {
+		t.Fatalf("expected a due soon section, got: %s", stdout)
+	}
+	if !strings.Contains(overdue, "Bob (every 3w-5w, last contact 1m ago)") || strings.Contains(overdue, "Alice") {
+		t.Errorf("expected only Bob to be overdue, got: %s", stdout)
+	}
+	if !strings.Contains(soon, "Alice (every 3w-5w, last contact 4w ago, due by "+date(7)+")") {
+		t.Errorf("expected Alice to be due soon, got: %s", stdout)
+	}
+	if strings.Contains(stdout, "Carol") {
+		t.Errorf("Carol's window hasn't opened, got: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "list", "--json")
+	if err != nil {
+		t.Fatalf("frm list --json failed: %v", err)
+	}
+	var list []map[string]any
+	if err := json.Unmarshal([]byte(stdout), &list); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout)
+	}
+	for _, e := range list {
+		if e["name"] != "Alice" {
+			continue
+		}
+		if e["due_from"] != date(-7) || e["due_by"] != date(7) || e["due_in_days"] != float64(7) {
+			t.Errorf("unexpected window for Alice: %v", e)
+		}
+	}
+	stdout, _, _ = env.run(t, "list")
+	if !strings.Contains(stdout, date(-7)+" to "+date(7)+" (due soon)") || !strings.Contains(stdout, date(14)+" to "+date(28)+" (in 14d)") {
+		t.Errorf("expected list to show both dates of each window, got: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "check", "--json")
+	if err != nil {
+		t.Fatalf("frm check --json failed: %v", err)
+	}
+	var result struct {
+		Contacts []map[string]any `json:"contacts"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout)
+	}
+	for _, c := range result.Contacts {
+		if (c["name"] == "Alice") != (c["due_soon"] == true) {
+			t.Errorf("expected only Alice to be due soon, got %v", c)
+		}
+	}
+}
+
+func TestE2E_SuggestFrequency(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "3m")
+	env.backend.seedContact("Bob", "1m")
+	env.backend.seedContact("Carol", "1m")
+	env.backend.seedContact("Dave", "")
+
+	// Alice talks every two weeks, Bob about monthly, Dave weekly; Carol
+	// has too little history.
+	today := dateOnly(time.Now())
+	logAt := func(name string, days ...int) {
+		for _, d := range days {
+			when := today.AddDate(0, 0, -d).Format("2006-01-02")
+			if _, stderr, err := env.run(t, "log", name, "--when", when); err != nil {
+				t.Fatalf("frm log %s failed: %v\nstderr: %s", name, err, stderr)
+			}
+		}
+	}
+	logAt("Alice", 84, 70, 56, 42, 28, 14, 0)
+	logAt("Bob", 125, 95, 62, 33, 3)
+	logAt("Carol", 40, 10)
+	logAt("Dave", 28, 21, 14, 7, 0)
+
+	if _, _, err := env.run(t, "suggest-frequency", "Alice", "--all"); err == nil {
+		t.Error("expected a name together with --all to fail")
+	}
+
+	stdout, _, err := env.run(t, "suggest-frequency", "--all", "--json")
+	if err != nil {
+		t.Fatalf("frm suggest-frequency --all --json failed: %v", err)
+	}
+	var suggestions []frequencySuggestion
+	if err := json.Unmarshal([]byte(stdout), &suggestions); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout)
+	}
+	got := make(map[string]frequencySuggestion)
+	for _, s := range suggestions {
+		got[s.Name] = s
+	}
+	if len(got) != 3 {
+		t.Errorf("expected the three tracked contacts, got: %s", stdout)
+	}
+	if a := got["Alice"]; a.Suggested != "2w" || !a.Change || a.Confidence != "high" || a.Gaps != 6 || a.MedianDays != 14 {
+		t.Errorf("expected Alice to move to 2w with high confidence, got %+v", a)
+	}
+	if b := got["Bob"]; b.Suggested != "1m" || b.Change || b.Confidence == "none" {
+		t.Errorf("expected Bob to keep 1m, got %+v", b)
+	}
+	if c := got["Carol"]; c.Suggested != "" || c.Confidence != "none" {
+		t.Errorf("expected no suggestion for Carol, got %+v", c)
+	}
+
+	stdout, _, err = env.run(t, "suggest-frequency", "--all")
+	if err != nil {
+		t.Fatalf("frm suggest-frequency --all failed: %v", err)
+	}
+	for _, want := range []string{
+		"Alice: every 3m → 2w (high confidence)",
+		"median gap 14 days over 6 gaps; usually sooner than every 3m",
+		"Bob: keep every 1m",
+		"Not enough history to suggest for yet: 1",
+		"Frequencies to update: 1 (run with --apply)",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("expected %q in output, got: %s", want, stdout)
+		}
+	}
+	if freq := env.getContactCard("Alice").PreferredValue(fieldFrequency); freq != "3m" {
+		t.Errorf("expected no change without --apply, got %q", freq)
+	}
+
+	stdout, _, err = env.run(t, "suggest-frequency", "--all", "--apply", "--dry-run")
+	if err != nil {
+		t.Fatalf("frm suggest-frequency --apply --dry-run failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Would update 1 frequencies (dry run)") {
+		t.Errorf("expected a dry-run notice, got: %s", stdout)
+	}
+	if freq := env.getContactCard("Alice").PreferredValue(fieldFrequency); freq != "3m" {
+		t.Errorf("expected no change with --dry-run, got %q", freq)
+	}
+
+	if _, _, err := env.run(t, "suggest-frequency", "--all", "--apply"); err != nil {
+		t.Fatalf("frm suggest-frequency --all --apply failed: %v", err)
+	}
+	if freq := env.getContactCard("Alice").PreferredValue(fieldFrequency); freq != "2w" {
+		t.Errorf("expected Alice tracked every 2w, got %q", freq)
+	}
+	if freq := env.getContactCard("Bob").PreferredValue(fieldFrequency); freq != "1m" {
+		t.Errorf("expected Bob unchanged, got %q", freq)
+	}
+
+	// An untracked contact can be given a frequency from their history.
+	stdout, _, err = env.run(t, "suggest-frequency", "Dave", "--apply")
+	if err != nil {
+		t.Fatalf("frm suggest-frequency Dave --apply failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Dave: track every 1w") {
+		t.Errorf("expected a weekly suggestion for Dave, got: %s", stdout)
+	}
+	if freq := env.getContactCard("Dave").PreferredValue(fieldFrequency); freq != "1w" {
+		t.Errorf("expected Dave tracked every 1w, got %q", freq)
+	}
+}
+
+func TestSuggestFrequencyAtCadenceEnds(t *testing.T) {
+	gapsOf := func(days float64) []float64 {
+		return []float64{days, days, days, days, days, days}
+	}
+	for _, tc := range []struct {
+		current string
+		gap     float64
+		reason  string
+	}{
+		{"1y", 600, "usually later than every 1y"},
+		{"3d", 1, "usually sooner than every 3d"},
+		{"1m", 35, "usually later than every 1m"},
+	} {
+		s := suggestFrequency("Alice", tc.current, gapsOf(tc.gap))
+		if s.Suggested != tc.current || s.Change {
+			t.Errorf("%s with %v-day gaps: got suggested=%q change=%v, want %q unchanged", tc.current, tc.gap, s.Suggested, s.Change, tc.current)
+		}
+		if !strings.Contains(s.Reason, tc.reason) {
+			t.Errorf("%s with %v-day gaps: expected reason %q, got %q", tc.current, tc.gap, tc.reason, s.Reason)
+		}
+	}
+}
+
 func TestE2E_Untrack(t *testing.T) {
 	env := setupTest(t)
 	env.backend.seedContact("Alice", "2w")
@@ -479,16 +921,18 @@ func TestE2E_CheckJSONEnriched(t *testing.T) {
 		t.Fatalf("frm check --json failed: %v\nstderr: %s\nstdout: %s", err, stderr, stdout)
 	}
 
-	var result []map[string]any
+	var result struct {
+		Contacts []map[string]any `json:"contacts"`
+	}
 	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
 		t.Fatalf("invalid JSON: %v\noutput: %s", err, stdout)
 	}
 
-	if len(result) != 1 {
-		t.Fatalf("expected 1 overdue contact, got %d: %s", len(result), stdout)
+	if len(result.Contacts) != 1 {
+		t.Fatalf("expected 1 overdue contact, got %d: %s", len(result.Contacts), stdout)
 	}
 
-	c := result[0]
+	c := result.Contacts[0]
 	if c["name"] != "Alice" {
 		t.Errorf("expected name Alice, got %v", c["name"])
 	}
@@ -518,6 +962,100 @@ func TestE2E_CheckJSONEnriched(t *testing.T) {
 	}
 }
 
+func TestE2E_CheckFilters(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "1w")
+	env.backend.seedContact("Bob", "1w")
+	env.backend.seedContact("Carol", "1m")
+	env.backend.seedContact("Dave", "2w")
+	env.backend.seedContact("Erin", "1w")
+
+	today := dateOnly(time.Now())
+	ago := func(days int) string { return today.AddDate(0, 0, -days).Format("2006-01-02") }
+	for _, args := range [][]string{
+		{"log", "Alice", "--when", ago(10)},
+		{"log", "Carol", "--when", ago(25)},
+		{"group", "set", "Alice", "family"},
+		{"group", "set", "Bob", "family"},
+		{"group", "set", "Carol", "family"},
+		{"group", "set", "Erin", "family"},
+		{"snooze", "Erin", "--until", "2m"},
+	} {
+		if _, stderr, err := env.run(t, args...); err != nil {
+			t.Fatalf("frm %v failed: %v\nstderr: %s", args, err, stderr)
+		}
+	}
+
+	stdout, _, err := env.run(t, "check")
+	if err != nil {
+		t.Fatalf("frm check failed: %v", err)
+	}
+	for _, name := range []string{"Alice", "Bob", "Dave"} {
+		if !strings.Contains(stdout, name) {
+			t.Errorf("expected %s to be overdue, got: %s", name, stdout)
+		}
+	}
+	if strings.Contains(stdout, "Carol") || strings.Contains(stdout, "Erin") {
+		t.Errorf("Carol isn't due yet and Erin is snoozed, got: %s", stdout)
+	}
+
+	// Weekly planning: family members due this week, never-contacted first,
+	// then the longest overdue.
+	stdout, _, err = env.run(t, "check", "--within", "7d", "--group", "Family")
+	if err != nil {
+		t.Fatalf("frm check --within failed: %v", err)
+	}
+	if !strings.HasPrefix(stdout, "Due within 7d:\n") {
+		t.Errorf("expected a due-within header, got: %s", stdout)
+	}
+	bob, alice, carol := strings.Index(stdout, "Bob"), strings.Index(stdout, "Alice"), strings.Index(stdout, "Carol")
+	if bob < 0 || alice < bob || carol < alice {
+		t.Errorf("expected Bob, Alice, then Carol, got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "Carol (every 1m, last contact 3w ago, due in ") {
+		t.Errorf("expected Carol to be due in a few days, got: %s", stdout)
+	}
+	if strings.Contains(stdout, "Dave") || strings.Contains(stdout, "Erin") {
+		t.Errorf("expected only unsnoozed family members, got: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "check", "--group", "family", "--include-snoozed", "--sort", "name", "--json")
+	if err != nil {
+		t.Fatalf("frm check --json failed: %v", err)
+	}
+	var result struct {
+		Contacts []map[string]any `json:"contacts"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("invalid JSON: %v\noutput: %s", err, stdout)
+	}
+	var names []string
+	for _, c := range result.Contacts {
+		names = append(names, c["name"].(string))
+	}
+	if strings.Join(names, ",") != "Alice,Bob,Erin" {
+		t.Fatalf("expected Alice, Bob, Erin by name, got %v", names)
+	}
+	if days, _ := result.Contacts[0]["due_in_days"].(float64); days >= 0 || result.Contacts[0]["due"] != ago(3) {
+		t.Errorf("expected Alice due 3 days ago, got %v", result.Contacts[0])
+	}
+	if result.Contacts[2]["snoozed_until"] == nil || result.Contacts[1]["snoozed_until"] != nil {
+		t.Errorf("expected only Erin to be snoozed, got %v", result.Contacts)
+	}
+
+	stdout, _, err = env.run(t, "check", "--sort", "frequency", "--limit", "2")
+	if err != nil {
+		t.Fatalf("frm check --limit failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Alice") || !strings.Contains(stdout, "Bob") || strings.Contains(stdout, "Dave") {
+		t.Errorf("expected the two weekly contacts, got: %s", stdout)
+	}
+
+	if _, _, err := env.run(t, "check", "--sort", "age"); err == nil {
+		t.Error("expected an unknown --sort to fail")
+	}
+}
+
 func TestE2E_Triage(t *testing.T) {
 	env := setupTest(t)
 	env.backend.seedContact("Alice", "")
@@ -686,6 +1224,18 @@ func TestE2E_Context(t *testing.T) {
 	if !strings.Contains(stdout, "dinner") {
 		t.Errorf("expected last note in context, got: %s", stdout)
 	}
+
+	// A backdated entry logged afterwards, as sync-mail writes them, isn't
+	// the latest interaction.
+	old := time.Now().AddDate(0, 0, -21).Format("2006-01-02")
+	env.run(t, "log", "Alice", "--note", "old email", "--when", old)
+	stdout, _, err = env.run(t, "context", "Alice")
+	if err != nil {
+		t.Fatalf("frm context failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Last note: dinner") || strings.Contains(stdout, old) {
+		t.Errorf("expected the latest interaction, not the last logged, got: %s", stdout)
+	}
 }
 
 func TestE2E_Unignore(t *testing.T) {
@@ -849,31 +1399,132 @@ func TestE2E_Group(t *testing.T) {
 	}
 }
 
-func TestE2E_JSON(t *testing.T) {
+func TestE2E_GroupPolicies(t *testing.T) {
 	env := setupTest(t)
-	env.backend.seedContact("Alice", "2w")
-	env.backend.seedContact("Bob", "")
+	env.backend.seedContact("Alice", "")
+	env.backend.seedContact("Bob", "1m")
+	env.backend.seedContact("Carol", "")
+	for _, args := range [][]string{
+		{"group", "set", "Alice", "family"},
+		{"group", "set", "Bob", "colleagues"},
+	} {
+		if _, stderr, err := env.run(t, args...); err != nil {
+			t.Fatalf("frm %v failed: %v\nstderr: %s", args, err, stderr)
+		}
+	}
 
-	// list --all --json (replaces old contacts --json)
-	stdout, _, err := env.run(t, "list", "--all", "--json")
+	// Alice has no frequency of her own, so she inherits the family's.
+	stdout, _, err := env.run(t, "group", "config", "family", "--every", "biweekly")
 	if err != nil {
-		t.Fatalf("frm list --all --json failed: %v", err)
+		t.Fatalf("frm group config failed: %v", err)
 	}
-	if !strings.Contains(stdout, `"Alice"`) {
-		t.Errorf("expected Alice in JSON output, got: %s", stdout)
+	if !strings.Contains(stdout, "Set group family: every 2w") {
+		t.Errorf("unexpected output: %s", stdout)
+	}
+	if _, _, err := env.run(t, "group", "config", "family", "--every", "often"); err == nil {
+		t.Error("expected an invalid frequency to fail")
+	}
+	if card := env.getContactCard("Alice"); card.PreferredValue(fieldFrequency) != "" {
+		t.Error("the group default shouldn't be written to the card")
 	}
 
-	// check --json
-	stdout, _, err = env.run(t, "check", "--json")
-	if err != nil {
-		t.Fatalf("frm check --json failed: %v", err)
+	stdout, _, _ = env.run(t, "check")
+	if !strings.Contains(stdout, "Alice (every 2w, never contacted)") || !strings.Contains(stdout, "Bob") {
+		t.Errorf("expected Alice and Bob overdue, got: %s", stdout)
 	}
-	if !strings.Contains(stdout, `"name"`) {
-		t.Errorf("expected JSON object with name field, got: %s", stdout)
+	if strings.Contains(stdout, "Carol") {
+		t.Errorf("Carol isn't tracked, got: %s", stdout)
 	}
 
-	// context --json
-	stdout, _, err = env.run(t, "context", "Alice", "--json")
+	stdout, _, err = env.run(t, "list", "--json")
+	if err != nil {
+		t.Fatalf("frm list --json failed: %v", err)
+	}
+	var list []map[string]any
+	if err := json.Unmarshal([]byte(stdout), &list); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout)
+	}
+	if len(list) != 2 || list[0]["name"] != "Alice" || list[0]["frequency"] != "2w" || list[0]["frequency_from_group"] != true {
+		t.Errorf("expected Alice listed at the family's 2w, got: %s", stdout)
+	}
+
+	// A weighted group comes first when sorting by priority.
+	if _, _, err := env.run(t, "group", "config", "colleagues", "--weight", "3"); err != nil {
+		t.Fatalf("frm group config --weight failed: %v", err)
+	}
+	stdout, _, _ = env.run(t, "check", "--sort", "priority")
+	if a, b := strings.Index(stdout, "Alice"), strings.Index(stdout, "Bob"); b < 0 || b > a {
+		t.Errorf("expected Bob before Alice by priority, got: %s", stdout)
+	}
+
+	until := dateOnly(time.Now()).AddDate(0, 0, 30).Format("2006-01-02")
+	stdout, _, err = env.run(t, "group", "snooze", "colleagues", "--until", until)
+	if err != nil {
+		t.Fatalf("frm group snooze failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Snoozed group colleagues until "+until) {
+		t.Errorf("unexpected output: %s", stdout)
+	}
+	stdout, _, _ = env.run(t, "check")
+	if strings.Contains(stdout, "Bob") || !strings.Contains(stdout, "Alice") {
+		t.Errorf("expected the colleagues group snoozed, got: %s", stdout)
+	}
+	stdout, _, _ = env.run(t, "check", "--include-snoozed")
+	if !strings.Contains(stdout, "Bob (every 1m, never contacted, group snoozed until "+until+")") {
+		t.Errorf("expected Bob shown as group snoozed, got: %s", stdout)
+	}
+
+	stdout, _, _ = env.run(t, "group", "list")
+	if !strings.Contains(stdout, "colleagues (1): weight 3, snoozed until "+until) || !strings.Contains(stdout, "family (1): every 2w") {
+		t.Errorf("expected group settings in the list, got: %s", stdout)
+	}
+
+	if _, _, err := env.run(t, "group", "unsnooze", "colleagues"); err != nil {
+		t.Fatalf("frm group unsnooze failed: %v", err)
+	}
+	stdout, _, _ = env.run(t, "check")
+	if !strings.Contains(stdout, "Bob") {
+		t.Errorf("expected Bob back after unsnoozing the group, got: %s", stdout)
+	}
+
+	data, err := os.ReadFile(filepath.Join(env.configDir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	if cfg.Groups["family"].Frequency != "2w" || cfg.Groups["colleagues"] != (GroupConfig{Weight: 3}) || len(cfg.Services) == 0 {
+		t.Errorf("unexpected config after group changes: %s", data)
+	}
+}
+
+func TestE2E_JSON(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "2w")
+	env.backend.seedContact("Bob", "")
+
+	// list --all --json (replaces old contacts --json)
+	stdout, _, err := env.run(t, "list", "--all", "--json")
+	if err != nil {
+		t.Fatalf("frm list --all --json failed: %v", err)
+	}
+	if !strings.Contains(stdout, `"Alice"`) {
+		t.Errorf("expected Alice in JSON output, got: %s", stdout)
+	}
+
+	// check --json
+	stdout, _, err = env.run(t, "check", "--json")
+	if err != nil {
+		t.Fatalf("frm check --json failed: %v", err)
+	}
+	if !strings.Contains(stdout, `"name"`) {
+		t.Errorf("expected JSON object with name field, got: %s", stdout)
+	}
+
+	// context --json
+	stdout, _, err = env.run(t, "context", "Alice", "--json")
 	if err != nil {
 		t.Fatalf("frm context --json failed: %v", err)
 	}
@@ -913,17 +1564,30 @@ func TestE2E_LogPathNormalization(t *testing.T) {
 type mockMessage struct {
 	Subject    string
 	ReceivedAt string // RFC3339
+
+	// Optional headers, for sync-mail.
+	MessageID string
+	From      string
+	To        []string
+
+	// Optional thread details.
+	ThreadID string
+	Preview  string
+	Keywords []string
 }
 
-func newMockJMAPServer(messages map[string][]mockMessage) *httptest.Server {
+// newMockJMAPServer serves messages by address. If requests is non-nil, it
+// counts API requests.
+func newMockJMAPServer(messages map[string][]mockMessage, requests *atomic.Int32) *httptest.Server {
 	mux := http.NewServeMux()
 
 	var apiURL string
 	mux.HandleFunc("/jmap/session", func(w http.ResponseWriter, r *http.Request) {
 		session := map[string]any{
 			"capabilities": map[string]any{
-				"urn:ietf:params:jmap:core": map[string]any{},
-				"urn:ietf:params:jmap:mail": map[string]any{},
+				"urn:ietf:params:jmap:core":       map[string]any{},
+				"urn:ietf:params:jmap:mail":       map[string]any{},
+				"urn:ietf:params:jmap:submission": map[string]any{},
 			},
 			"accounts": map[string]any{
 				"a1": map[string]any{
@@ -931,7 +1595,8 @@ func newMockJMAPServer(messages map[string][]mockMessage) *httptest.Server {
 				},
 			},
 			"primaryAccounts": map[string]any{
-				"urn:ietf:params:jmap:mail": "a1",
+				"urn:ietf:params:jmap:mail":       "a1",
+				"urn:ietf:params:jmap:submission": "a1",
 			},
 			"apiUrl":   apiURL,
 			"state":    "0",
@@ -942,6 +1607,9 @@ func newMockJMAPServer(messages map[string][]mockMessage) *httptest.Server {
 	})
 
 	mux.HandleFunc("/jmap/api", func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			requests.Add(1)
+		}
 		var req struct {
 			Calls []json.RawMessage `json:"methodCalls"`
 		}
@@ -950,63 +1618,105 @@ func newMockJMAPServer(messages map[string][]mockMessage) *httptest.Server {
 			return
 		}
 
-		// Collect email addresses from the query filter
-		var matchAddrs []string
-		var queryCallID string
+		var responses []any
+		var matched []mockMessage
 		for _, raw := range req.Calls {
 			var call []json.RawMessage
 			json.Unmarshal(raw, &call)
-			var method string
+			var method, callID string
 			json.Unmarshal(call[0], &method)
-			if method == "Email/query" {
-				json.Unmarshal(call[2], &queryCallID)
+			json.Unmarshal(call[2], &callID)
+
+			switch method {
+			case "Identity/get":
+				responses = append(responses, []any{"Identity/get", map[string]any{
+					"accountId": "a1",
+					"state":     "0",
+					"list":      []any{map[string]any{"id": "i1", "email": "me@example.com"}},
+				}, callID})
+
+			case "Email/query":
 				var args struct {
 					Filter json.RawMessage `json:"filter"`
+					Sort   []struct {
+						IsAscending bool `json:"isAscending"`
+					} `json:"sort"`
+					CollapseThreads bool `json:"collapseThreads"`
+					Limit           int  `json:"limit"`
 				}
 				json.Unmarshal(call[1], &args)
-				matchAddrs = extractFilterAddrs(args.Filter)
-			}
-		}
-
-		// Find matching messages
-		var matched []mockMessage
-		seen := make(map[string]bool)
-		for _, addr := range matchAddrs {
-			for _, msg := range messages[addr] {
-				key := msg.Subject + msg.ReceivedAt
-				if !seen[key] {
-					seen[key] = true
-					matched = append(matched, msg)
+				matched = matchMockMessages(messages, args.Filter)
+				if len(args.Sort) > 0 && !args.Sort[0].IsAscending {
+					sort.SliceStable(matched, func(i, j int) bool { return matched[i].ReceivedAt > matched[j].ReceivedAt })
+				}
+				if args.CollapseThreads {
+					threads := make(map[string]bool)
+					var latest []mockMessage
+					for _, msg := range matched {
+						if msg.ThreadID == "" || !threads[msg.ThreadID] {
+							threads[msg.ThreadID] = true
+							latest = append(latest, msg)
+						}
+					}
+					matched = latest
+				}
+				if args.Limit > 0 && len(matched) > args.Limit {
+					matched = matched[:args.Limit]
 				}
+				var ids []string
+				for i := range matched {
+					ids = append(ids, fmt.Sprintf("msg-%d", i))
+				}
+				responses = append(responses, []any{"Email/query", map[string]any{
+					"accountId":  "a1",
+					"ids":        ids,
+					"queryState": "0",
+				}, callID})
+
+			case "Email/get":
+				var emailList []map[string]any
+				for i, msg := range matched {
+					e := map[string]any{
+						"id":         fmt.Sprintf("msg-%d", i),
+						"subject":    msg.Subject,
+						"receivedAt": msg.ReceivedAt,
+					}
+					if msg.MessageID != "" {
+						e["messageId"] = []string{msg.MessageID}
+					}
+					if msg.From != "" {
+						e["from"] = []any{map[string]any{"email": msg.From}}
+					}
+					var to []any
+					for _, addr := range msg.To {
+						to = append(to, map[string]any{"email": addr})
+					}
+					if to != nil {
+						e["to"] = to
+					}
+					if msg.ThreadID != "" {
+						e["threadId"] = msg.ThreadID
+					}
+					if msg.Preview != "" {
+						e["preview"] = msg.Preview
+					}
+					if msg.Keywords != nil {
+						keywords := make(map[string]bool)
+						for _, k := range msg.Keywords {
+							keywords[k] = true
+						}
+						e["keywords"] = keywords
+					}
+					emailList = append(emailList, e)
+				}
+				responses = append(responses, []any{"Email/get", map[string]any{
+					"accountId": "a1",
+					"state":     "0",
+					"list":      emailList,
+				}, callID})
 			}
 		}
 
-		// Build email IDs and email objects
-		var ids []string
-		var emailList []map[string]any
-		for i, msg := range matched {
-			id := fmt.Sprintf("msg-%d", i)
-			ids = append(ids, id)
-			emailList = append(emailList, map[string]any{
-				"id":         id,
-				"subject":    msg.Subject,
-				"receivedAt": msg.ReceivedAt,
-			})
-		}
-
-		responses := []any{
-			[]any{"Email/query", map[string]any{
-				"accountId":  "a1",
-				"ids":        ids,
-				"queryState": "0",
-			}, queryCallID},
-			[]any{"Email/get", map[string]any{
-				"accountId": "a1",
-				"state":     "0",
-				"list":      emailList,
-			}, "1"},
-		}
-
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]any{
 			"methodResponses": responses,
@@ -1051,10 +1761,72 @@ func extractFilterAddrs(raw json.RawMessage) []string {
 	return addrs
 }
 
+// extractFilterAfter returns the first "after" condition in a filter.
+func extractFilterAfter(raw json.RawMessage) string {
+	var obj struct {
+		After      string            `json:"after"`
+		Conditions []json.RawMessage `json:"conditions"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return ""
+	}
+	if obj.After != "" {
+		return obj.After
+	}
+	for _, c := range obj.Conditions {
+		if after := extractFilterAfter(c); after != "" {
+			return after
+		}
+	}
+	return ""
+}
+
+// matchMockMessages returns the messages an Email/query filter selects: those
+// filed under its from/to addresses (received after its "after" condition, if
+// any) or, for a bare "after" filter, every message received since then,
+// oldest first.
+func matchMockMessages(messages map[string][]mockMessage, filter json.RawMessage) []mockMessage {
+	var matched []mockMessage
+	seen := make(map[string]bool)
+	add := func(msg mockMessage) {
+		key := msg.Subject + msg.ReceivedAt
+		if !seen[key] {
+			seen[key] = true
+			matched = append(matched, msg)
+		}
+	}
+	after := extractFilterAfter(filter)
+	if addrs := extractFilterAddrs(filter); len(addrs) > 0 {
+		for _, addr := range addrs {
+			for _, msg := range messages[addr] {
+				if msg.ReceivedAt >= after {
+					add(msg)
+				}
+			}
+		}
+		return matched
+	}
+	if after == "" {
+		return matched
+	}
+	var all []mockMessage
+	for _, msgs := range messages {
+		all = append(all, msgs...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ReceivedAt < all[j].ReceivedAt })
+	for _, msg := range all {
+		if msg.ReceivedAt >= after {
+			add(msg)
+		}
+	}
+	return matched
+}
+
 func setupTestWithJMAP(t *testing.T, messages map[string][]mockMessage) *testEnv {
 	t.Helper()
 
-	jmapServer := newMockJMAPServer(messages)
+	var jmapRequests atomic.Int32
+	jmapServer := newMockJMAPServer(messages, &jmapRequests)
 
 	backend := newMemBackend()
 	handler := &carddav.Handler{Backend: backend}
@@ -1091,9 +1863,10 @@ func setupTestWithJMAP(t *testing.T, messages map[string][]mockMessage) *testEnv
 	})
 
 	return &testEnv{
-		server:    server,
-		backend:   backend,
-		configDir: configDir,
+		server:       server,
+		backend:      backend,
+		configDir:    configDir,
+		jmapRequests: &jmapRequests,
 	}
 }
 
@@ -1123,6 +1896,107 @@ func TestE2E_ContextWithJMAP(t *testing.T) {
 	}
 }
 
+func TestE2E_ContextRefresh(t *testing.T) {
+	messages := map[string][]mockMessage{
+		"alice@example.com": {{Subject: "Weekend plans?", ReceivedAt: "2024-01-15T10:00:00Z"}},
+	}
+	env := setupTestWithJMAP(t, messages)
+	env.backend.seedContactWithEmail("Alice", "2w", "alice@example.com")
+
+	if _, stderr, err := env.run(t, "context", "Alice"); err != nil {
+		t.Fatalf("frm context failed: %v\nstderr: %s", err, stderr)
+	}
+	first := env.jmapRequests.Load()
+	if _, _, err := env.run(t, "context", "Alice"); err != nil {
+		t.Fatalf("frm context failed: %v", err)
+	}
+	if n := env.jmapRequests.Load(); n != first {
+		t.Errorf("expected the second lookup to be cached, requests went from %d to %d", first, n)
+	}
+
+	// --refresh goes back to the server and caches what it finds.
+	stdout, _, err := env.run(t, "context", "Alice", "--refresh")
+	if err != nil {
+		t.Fatalf("frm context --refresh failed: %v", err)
+	}
+	if n := env.jmapRequests.Load(); n == first {
+		t.Error("expected --refresh to skip the cache")
+	}
+	if !strings.Contains(stdout, "Weekend plans?") {
+		t.Errorf("expected the refreshed email, got: %s", stdout)
+	}
+	refreshed := env.jmapRequests.Load()
+	env.run(t, "context", "Alice")
+	if n := env.jmapRequests.Load(); n != refreshed {
+		t.Errorf("expected refreshed results to be cached, requests went from %d to %d", refreshed, n)
+	}
+}
+
+func TestE2E_ContextJMAPThreads(t *testing.T) {
+	day := func(n int) string { return time.Now().UTC().AddDate(0, 0, -n).Format(time.RFC3339) }
+	messages := map[string][]mockMessage{
+		"alice@example.com": {
+			{Subject: "Weekend plans?", ReceivedAt: day(2), ThreadID: "t1", From: "alice@example.com", To: []string{"me@example.com"}, Preview: "Are you free on Saturday?\nThinking of a hike."},
+			{Subject: "Re: Quarterly report", ReceivedAt: day(4), ThreadID: "t2", From: "me@example.com", To: []string{"alice@example.com"}, Preview: "Attached."},
+			{Subject: "Weekend plans?", ReceivedAt: day(5), ThreadID: "t1", From: "me@example.com", To: []string{"alice@example.com"}},
+			{Subject: "Thanks!", ReceivedAt: day(10), ThreadID: "t3", From: "alice@example.com", Keywords: []string{"$seen", "$answered"}},
+			{Subject: "Ancient history", ReceivedAt: day(200), ThreadID: "t4", From: "alice@example.com"},
+		},
+	}
+	jmapServer := newMockJMAPServer(messages, nil)
+	t.Cleanup(jmapServer.Close)
+	env := setupTest(t)
+	env.addService(t, ServiceConfig{
+		Type:            "jmap",
+		SessionEndpoint: jmapServer.URL + "/jmap/session",
+		Token:           "test-token",
+		MaxResults:      5,
+		Window:          "90d",
+		URLTemplate:     "https://mail.example.com/{thread_id}/{id}",
+	})
+	env.backend.seedContactWithEmail("Alice", "2w", "alice@example.com")
+
+	stdout, stderr, err := env.run(t, "context", "Alice")
+	if err != nil {
+		t.Fatalf("frm context failed: %v\nstderr: %s", err, stderr)
+	}
+	want := "  Weekend plans? (" + day(2)[:10] + ", from alice@example.com) [awaiting your reply]\n    Are you free on Saturday? Thinking of a hike.\n    https://mail.example.com/t1/msg-0\n"
+	if !strings.Contains(stdout, want) {
+		t.Errorf("expected the latest Weekend plans message flagged, got: %s", stdout)
+	}
+	if strings.Count(stdout, "Weekend plans?") != 1 {
+		t.Errorf("expected one line per thread, got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "  Re: Quarterly report ("+day(4)[:10]+", from me@example.com)\n") {
+		t.Errorf("expected my reply without a flag, got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "  Thanks! ("+day(10)[:10]+", from alice@example.com)\n") {
+		t.Errorf("expected an answered thread without a flag, got: %s", stdout)
+	}
+	if strings.Contains(stdout, "Ancient history") {
+		t.Errorf("expected mail outside the window to be left out, got: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "context", "Alice", "--json")
+	if err != nil {
+		t.Fatalf("frm context --json failed: %v", err)
+	}
+	var result struct {
+		Providers []providerContext `json:"providers"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout)
+	}
+	if len(result.Providers) != 1 || len(result.Providers[0].Items) != 3 {
+		t.Fatalf("expected three threads, got: %s", stdout)
+	}
+	first := result.Providers[0].Items[0]
+	if first.ThreadID != "t1" || first.From != "alice@example.com" || !first.AwaitingReply ||
+		first.Snippet != "Are you free on Saturday?\nThinking of a hike." || first.URL != "https://mail.example.com/t1/msg-0" {
+		t.Errorf("unexpected first thread: %+v", first)
+	}
+}
+
 func TestE2E_TriageWithJMAP(t *testing.T) {
 	messages := map[string][]mockMessage{
 		"alice@example.com": {
@@ -1145,6 +2019,52 @@ func TestE2E_TriageWithJMAP(t *testing.T) {
 	}
 }
 
+func TestE2E_TriageContextBatchedAndCached(t *testing.T) {
+	messages := map[string][]mockMessage{}
+	for i := range 20 {
+		messages[fmt.Sprintf("p%02d@example.com", i)] = []mockMessage{{Subject: fmt.Sprintf("Hello from Person %02d", i), ReceivedAt: "2024-01-15T10:00:00Z"}}
+	}
+	env := setupTestWithJMAP(t, messages)
+	for i := range 20 {
+		env.backend.seedContactWithEmail(fmt.Sprintf("Person %02d", i), "", fmt.Sprintf("p%02d@example.com", i))
+	}
+
+	check := func() {
+		t.Helper()
+		stdout, stderr, err := env.run(t, "triage", "--json", "--limit", "-1")
+		if err != nil {
+			t.Fatalf("frm triage --json failed: %v\nstderr: %s", err, stderr)
+		}
+		var result []struct {
+			Name    string            `json:"name"`
+			Context []providerContext `json:"context"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, stdout)
+		}
+		if len(result) != 20 {
+			t.Fatalf("expected 20 contacts, got %d", len(result))
+		}
+		for _, r := range result {
+			if len(r.Context) != 1 || len(r.Context[0].Items) != 1 || r.Context[0].Items[0].Title != "Hello from "+r.Name {
+				t.Errorf("unexpected context for %s: %+v", r.Name, r.Context)
+			}
+		}
+	}
+
+	// 20 contacts fit in two batched requests rather than one each.
+	check()
+	if n := env.jmapRequests.Load(); n != 2 {
+		t.Errorf("expected 2 batched JMAP requests, got %d", n)
+	}
+
+	// A second run within the cache TTL doesn't hit the server at all.
+	check()
+	if n := env.jmapRequests.Load(); n != 2 {
+		t.Errorf("expected cached results, but JMAP requests went from 2 to %d", n)
+	}
+}
+
 func TestE2E_TriageJSON(t *testing.T) {
 	env := setupTest(t)
 	env.backend.seedContact("Alice", "2w")                     // has frequency — should be excluded
@@ -1732,6 +2652,49 @@ func TestE2E_InitAddService(t *testing.T) {
 	}
 }
 
+func TestE2E_InitAddServiceKeepsGroups(t *testing.T) {
+	configDir := t.TempDir()
+	initialCfg := Config{
+		Services: []ServiceConfig{{
+			Type:     "carddav",
+			Endpoint: "https://carddav.example.com/",
+			Username: "existing",
+			Password: "existing",
+		}},
+		Groups: map[string]GroupConfig{
+			"family":     {Weight: 2, Frequency: "2w"},
+			"colleagues": {SnoozeUntil: "2026-12-01"},
+		},
+	}
+	data, _ := json.Marshal(initialCfg)
+	os.WriteFile(filepath.Join(configDir, "config.json"), data, 0o644)
+
+	cmd := exec.Command(binaryPath, "init")
+	cmd.Env = append(os.Environ(), "FRM_CONFIG_DIR="+configDir)
+	cmd.Stdin = strings.NewReader("a\nj\nhttps://jmap.example.com/session\nmy-token\n")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("frm init (add) failed: %v\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("parsing config: %v", err)
+	}
+	if len(cfg.Services) != 2 {
+		t.Errorf("expected 2 services, got %d", len(cfg.Services))
+	}
+	if len(cfg.Groups) != 2 || cfg.Groups["family"] != initialCfg.Groups["family"] || cfg.Groups["colleagues"] != initialCfg.Groups["colleagues"] {
+		t.Errorf("expected group settings kept, got: %s", data)
+	}
+}
+
 func TestE2E_InitWithPreset(t *testing.T) {
 	configDir := t.TempDir()
 
@@ -2229,3 +3192,1577 @@ func TestE2E_DryRun(t *testing.T) {
 		}
 	})
 }
+
+func TestE2E_SyncCache(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "2w")
+	env.backend.seedContact("Bob", "1m")
+
+	// Offline reads need a populated cache.
+	_, stderr, err := env.run(t, "list", "--offline")
+	if err == nil {
+		t.Fatal("expected frm list --offline to fail before any sync")
+	}
+	if !strings.Contains(stderr, "frm sync") {
+		t.Errorf("expected hint to run frm sync, got: %s", stderr)
+	}
+
+	stdout, stderr, err := env.run(t, "sync")
+	if err != nil {
+		t.Fatalf("frm sync failed: %v\nstderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "2 contacts (2 updated, 0 removed)") {
+		t.Errorf("unexpected sync output: %s", stdout)
+	}
+
+	// A second sync finds nothing new.
+	stdout, _, err = env.run(t, "sync", "--json")
+	if err != nil {
+		t.Fatalf("frm sync --json failed: %v", err)
+	}
+	var results []map[string]any
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatalf("invalid JSON: %v\noutput: %s", err, stdout)
+	}
+	if len(results) != 1 || results[0]["contacts"] != float64(2) || results[0]["updated"] != float64(0) {
+		t.Errorf("unexpected sync result: %s", stdout)
+	}
+
+	// Server-side changes are picked up incrementally: Charlie added, Bob removed.
+	env.backend.seedContact("Charlie", "3m")
+	env.backend.mu.Lock()
+	delete(env.backend.contacts, abPath+"bob.vcf")
+	env.backend.mu.Unlock()
+
+	stdout, _, err = env.run(t, "list")
+	if err != nil {
+		t.Fatalf("frm list failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Charlie") || strings.Contains(stdout, "Bob") {
+		t.Errorf("expected cache to pick up Charlie and drop Bob, got: %s", stdout)
+	}
+
+	// Writes go through to the cache, so offline reads see them.
+	if _, _, err := env.run(t, "track", "Alice", "--every", "1w"); err != nil {
+		t.Fatalf("frm track failed: %v", err)
+	}
+	env.server.Close()
+	stdout, stderr, err = env.run(t, "list", "--offline")
+	if err != nil {
+		t.Fatalf("frm list --offline failed: %v\nstderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Alice") || !strings.Contains(stdout, "1w") || !strings.Contains(stdout, "Charlie") {
+		t.Errorf("expected cached contacts offline, got: %s", stdout)
+	}
+}
+
+func TestE2E_ConflictRetry(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContactWithEmail("Alice", "", "alice@example.com")
+
+	// Sync so frm reads Alice, then change her phone "on another device"
+	// just before frm writes.
+	if _, _, err := env.run(t, "sync"); err != nil {
+		t.Fatalf("frm sync failed: %v", err)
+	}
+	env.backend.mu.Lock()
+	env.backend.interfere = func(card vcard.Card) {
+		card[vcard.FieldTelephone] = []*vcard.Field{{Value: "555-0000"}}
+	}
+	env.backend.mu.Unlock()
+
+	stdout, stderr, err := env.run(t, "track", "Alice", "--every", "2w", "--json")
+	if err != nil {
+		t.Fatalf("frm track failed: %v\nstderr: %s", err, stderr)
+	}
+	var result map[string]any
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("invalid JSON: %v\noutput: %s", err, stdout)
+	}
+	if result["conflicts"] != float64(1) {
+		t.Errorf("expected conflicts=1, got %v", result["conflicts"])
+	}
+
+	// Both the concurrent edit and frm's change survive.
+	card := env.getContactCard("Alice")
+	if card.PreferredValue(vcard.FieldTelephone) != "555-0000" {
+		t.Errorf("concurrent phone edit was clobbered, got %q", card.PreferredValue(vcard.FieldTelephone))
+	}
+	if card.PreferredValue(fieldFrequency) != "2w" {
+		t.Errorf("expected frequency 2w, got %q", card.PreferredValue(fieldFrequency))
+	}
+
+	// Text mode reports the conflict too.
+	env.backend.mu.Lock()
+	env.backend.interfere = func(card vcard.Card) {
+		card[vcard.FieldOrganization] = []*vcard.Field{{Value: "Acme"}}
+	}
+	env.backend.mu.Unlock()
+	stdout, _, err = env.run(t, "group", "set", "Alice", "friends")
+	if err != nil {
+		t.Fatalf("frm group set failed: %v", err)
+	}
+	if !strings.Contains(stdout, "changed elsewhere") {
+		t.Errorf("expected conflict note, got: %s", stdout)
+	}
+	card = env.getContactCard("Alice")
+	if card.PreferredValue(vcard.FieldOrganization) != "Acme" || card.PreferredValue(fieldGroup) != "friends" {
+		t.Errorf("expected both org and group to be set, got org=%q group=%q",
+			card.PreferredValue(vcard.FieldOrganization), card.PreferredValue(fieldGroup))
+	}
+}
+
+// noETagWriter drops the ETag header, like servers that leave it off PUT
+// responses.
+type noETagWriter struct{ http.ResponseWriter }
+
+func (w noETagWriter) WriteHeader(code int) {
+	w.Header().Del("ETag")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func TestE2E_PutWithoutETag(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "")
+
+	handler := &carddav.Handler{Backend: env.backend}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			w = noETagWriter{w}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	env.updateConfig(t, func(cfg *Config) { cfg.Services[0].Endpoint = server.URL + "/" })
+
+	if _, stderr, err := env.run(t, "track", "Alice", "--every", "2w"); err != nil {
+		t.Fatalf("frm track failed: %v\nstderr: %s", err, stderr)
+	}
+
+	// The cached card carries the ETag the server now has, not an empty one,
+	// so the next guarded write still has something to send.
+	env.backend.mu.Lock()
+	var want string
+	for _, obj := range env.backend.contacts {
+		want = obj.ETag
+	}
+	env.backend.mu.Unlock()
+	caches, _ := filepath.Glob(filepath.Join(env.configDir, "cache", "carddav-*.json"))
+	if len(caches) != 1 {
+		t.Fatalf("expected one contact cache, got %v", caches)
+	}
+	data, err := os.ReadFile(caches[0])
+	if err != nil {
+		t.Fatalf("reading cache: %v", err)
+	}
+	var cache contactCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		t.Fatalf("invalid cache: %v", err)
+	}
+	for path, obj := range cache.Objects {
+		if obj.ETag != want {
+			t.Errorf("cached ETag for %s = %q, want %q", path, obj.ETag, want)
+		}
+	}
+}
+
+func TestE2E_AccountErrors(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "1w")
+
+	// Add a second account whose server is gone.
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	cfg := Config{
+		Services: []ServiceConfig{
+			{Type: "carddav", Endpoint: env.server.URL + "/", Username: "test", Password: "test"},
+			{Type: "carddav", Endpoint: dead.URL + "/", Username: "gone", Password: "test"},
+		},
+	}
+	data, _ := json.Marshal(cfg)
+	if err := os.WriteFile(filepath.Join(env.configDir, "config.json"), data, 0o644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	// The healthy account's contacts still show, with a warning for the other.
+	stdout, stderr, err := env.run(t, "list")
+	if err != nil {
+		t.Fatalf("frm list failed: %v\nstderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Alice") {
+		t.Errorf("expected Alice in list, got: %s", stdout)
+	}
+	if !strings.Contains(stderr, "warning: skipped gone") {
+		t.Errorf("expected warning for unreachable account, got: %s", stderr)
+	}
+
+	// JSON objects carry the failures inline.
+	stdout, _, err = env.run(t, "stats", "--json")
+	if err != nil {
+		t.Fatalf("frm stats --json failed: %v", err)
+	}
+	var stats struct {
+		Total  int            `json:"total_contacts"`
+		Errors []accountError `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &stats); err != nil {
+		t.Fatalf("parsing stats JSON: %v\n%s", err, stdout)
+	}
+	if stats.Total != 1 {
+		t.Errorf("expected 1 contact, got %d", stats.Total)
+	}
+	if len(stats.Errors) != 1 || !strings.HasPrefix(stats.Errors[0].Account, "gone") {
+		t.Errorf("expected one error for the unreachable account, got %+v", stats.Errors)
+	}
+
+	// List outputs are wrapped so the failures are still on stdout.
+	stdout, _, err = env.run(t, "check", "--json")
+	if err != nil {
+		t.Fatalf("frm check --json failed: %v", err)
+	}
+	var check struct {
+		Contacts []overdueContact `json:"contacts"`
+		Errors   []accountError   `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &check); err != nil {
+		t.Fatalf("parsing check JSON: %v\n%s", err, stdout)
+	}
+	if len(check.Contacts) != 1 || check.Contacts[0].Name != "Alice" || len(check.Errors) != 1 {
+		t.Errorf("expected Alice with one account error, got: %s", stdout)
+	}
+
+	// An account that fails on each fetch in a run is reported once.
+	svc := cfg.Services[1]
+	recordAccountError(svc, fmt.Errorf("connection refused"))
+	recordAccountError(svc, fmt.Errorf("connection reset"))
+	if len(accountErrors) != 1 || accountErrors[0].Error != "connection reset" {
+		t.Errorf("expected one error per account, got %+v", accountErrors)
+	}
+	accountErrors = nil
+
+	// --strict turns the warning into a failure.
+	if _, _, err := env.run(t, "list", "--strict"); err == nil {
+		t.Error("expected frm list --strict to fail")
+	}
+
+	// sync reports per account and keeps going.
+	stdout, stderr, err = env.run(t, "sync")
+	if err != nil {
+		t.Fatalf("frm sync failed: %v\nstderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Synced test") || !strings.Contains(stderr, "warning: skipped gone") {
+		t.Errorf("expected partial sync with warning, got stdout=%q stderr=%q", stdout, stderr)
+	}
+}
+
+func TestE2E_ImportLinkedIn(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContactFull("Bob Jones", "", "", "", "")                          // name match, gets org/title
+	env.backend.seedContactFull("Carol White", "", "", "", "Initech")                 // name match, has org
+	env.backend.seedContactFull("Robert Smith", "", "dana@example.com", "", "Globex") // email match
+
+	csvPath := filepath.Join(t.TempDir(), "Connections.csv")
+	csvData := `Notes:
+"When exporting your connection data, you may notice that some of the email addresses are missing."
+
+First Name,Last Name,URL,Email Address,Company,Position,Connected On
+Alice,Smith,https://www.linkedin.com/in/alicesmith,,Acme,Engineer,15 Jan 2024
+Bob,Jones,,,Globex,Manager,03 Feb 2023
+Carol,White,,,,,01 Mar 2022
+Dana,Smith,,dana@example.com,Globex,CTO,10 Oct 2021
+alice,smith,,,Acme,Engineer,15 Jan 2024
+`
+	if err := os.WriteFile(csvPath, []byte(csvData), 0o644); err != nil {
+		t.Fatalf("writing CSV: %v", err)
+	}
+
+	// Dry run reports without writing.
+	stdout, _, err := env.run(t, "import", "linkedin", csvPath, "--dry-run")
+	if err != nil {
+		t.Fatalf("frm import linkedin --dry-run failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Would create Alice Smith") || !strings.Contains(stdout, "Created: 1") {
+		t.Errorf("unexpected dry-run output: %s", stdout)
+	}
+	if env.getContactCard("Alice Smith") != nil {
+		t.Fatal("dry run should not create contacts")
+	}
+
+	stdout, _, err = env.run(t, "import", "linkedin", csvPath, "--json")
+	if err != nil {
+		t.Fatalf("frm import linkedin failed: %v", err)
+	}
+	var result struct {
+		Total   int `json:"total"`
+		Created int `json:"created"`
+		Updated int `json:"updated"`
+		Skipped int `json:"skipped"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, stdout)
+	}
+	// Alice created; Bob and Dana (matched by email) get missing fields;
+	// Carol and the duplicate Alice row are skipped.
+	if result.Total != 5 || result.Created != 1 || result.Updated != 2 || result.Skipped != 2 {
+		t.Errorf("unexpected summary: %+v", result)
+	}
+
+	alice := env.getContactCard("Alice Smith")
+	if alice == nil {
+		t.Fatal("expected Alice Smith to be created")
+	}
+	if alice.PreferredValue(vcard.FieldOrganization) != "Acme" || alice.PreferredValue(vcard.FieldTitle) != "Engineer" {
+		t.Errorf("unexpected org/title: %q / %q", alice.PreferredValue(vcard.FieldOrganization), alice.PreferredValue(vcard.FieldTitle))
+	}
+	if alice.PreferredValue(vcard.FieldNote) != "Connected on LinkedIn: 2024-01-15" {
+		t.Errorf("unexpected note: %q", alice.PreferredValue(vcard.FieldNote))
+	}
+	if alice.PreferredValue(vcard.FieldURL) != "https://www.linkedin.com/in/alicesmith" {
+		t.Errorf("unexpected URL: %q", alice.PreferredValue(vcard.FieldURL))
+	}
+	if env.getContactCard("Dana Smith") != nil {
+		t.Error("Dana should have matched Robert Smith by email")
+	}
+	if title := env.getContactCard("Robert Smith").PreferredValue(vcard.FieldTitle); title != "CTO" {
+		t.Errorf("expected Robert's title filled in, got %q", title)
+	}
+
+	bob := env.getContactCard("Bob Jones")
+	if bob.PreferredValue(vcard.FieldOrganization) != "Globex" || bob.PreferredValue(vcard.FieldTitle) != "Manager" {
+		t.Errorf("expected Bob's org/title filled in, got %q / %q", bob.PreferredValue(vcard.FieldOrganization), bob.PreferredValue(vcard.FieldTitle))
+	}
+
+	// Re-importing is a no-op.
+	stdout, _, err = env.run(t, "import", "linkedin", csvPath)
+	if err != nil {
+		t.Fatalf("second import failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Created: 0") || !strings.Contains(stdout, "Skipped: 5") {
+		t.Errorf("expected re-import to skip everything, got: %s", stdout)
+	}
+}
+
+func TestE2E_ImportCSV(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContactFull("Bob Jones", "2w", "bob@example.com", "", "")
+
+	csvPath := filepath.Join(t.TempDir(), "contacts.csv")
+	csvData := "Full Name,Mail,Cell,Employer\n" +
+		"Alice Smith,alice@example.com,555-1234,Acme\n" +
+		"Robert Jones,bob@example.com,,Globex\n" +
+		"Carol White,,,\n"
+	if err := os.WriteFile(csvPath, []byte(csvData), 0o644); err != nil {
+		t.Fatalf("writing CSV: %v", err)
+	}
+
+	// A mapping is required.
+	if _, _, err := env.run(t, "import", "csv", csvPath); err == nil {
+		t.Error("expected an error without --map")
+	}
+	// Mapped columns must exist.
+	if _, _, err := env.run(t, "import", "csv", csvPath, "--map", "Name=FN"); err == nil {
+		t.Error("expected an error for a missing column")
+	}
+
+	stdout, stderr, err := env.run(t, "import", "csv", csvPath,
+		"--map", "Full Name=FN,Mail=EMAIL,Cell=TEL,Employer=ORG",
+		"--track", "3m", "--group", "conference-2026", "--json")
+	if err != nil {
+		t.Fatalf("frm import csv failed: %v\nstderr: %s", err, stderr)
+	}
+	var result struct {
+		Created int `json:"created"`
+		Updated int `json:"updated"`
+		Skipped int `json:"skipped"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, stdout)
+	}
+	if result.Created != 2 || result.Updated != 1 || result.Skipped != 0 {
+		t.Errorf("unexpected summary: %+v", result)
+	}
+
+	alice := env.getContactCard("Alice Smith")
+	if alice == nil {
+		t.Fatal("expected Alice Smith to be created")
+	}
+	if alice.PreferredValue(vcard.FieldEmail) != "alice@example.com" || alice.PreferredValue(vcard.FieldTelephone) != "555-1234" {
+		t.Errorf("unexpected email/phone: %q / %q", alice.PreferredValue(vcard.FieldEmail), alice.PreferredValue(vcard.FieldTelephone))
+	}
+	if getFrequency(alice) != "3m" || getGroup(alice) != "conference-2026" {
+		t.Errorf("expected frequency and group stamped, got %q / %q", getFrequency(alice), getGroup(alice))
+	}
+
+	// Bob matched by email: org and group filled in, existing frequency kept.
+	bob := env.getContactCard("Bob Jones")
+	if bob.PreferredValue(vcard.FieldOrganization) != "Globex" {
+		t.Errorf("expected Bob's org filled in, got %q", bob.PreferredValue(vcard.FieldOrganization))
+	}
+	if getFrequency(bob) != "2w" || getGroup(bob) != "conference-2026" {
+		t.Errorf("expected Bob to keep 2w and join the group, got %q / %q", getFrequency(bob), getGroup(bob))
+	}
+	if env.getContactCard("Robert Jones") != nil {
+		t.Error("Robert Jones should have matched Bob by email")
+	}
+}
+
+func TestE2E_ImportVCF(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice Müller", "")
+
+	vcfPath := filepath.Join(t.TempDir(), "contacts.vcf")
+	vcfData := "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Muller Alice\r\nORG:Acme\r\nEND:VCARD\r\n" +
+		"BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Dana Lee\r\nTEL:555-9876\r\nADR:;;1 Main St;Springfield;;;\r\nEND:VCARD\r\n"
+	if err := os.WriteFile(vcfPath, []byte(vcfData), 0o644); err != nil {
+		t.Fatalf("writing vcf: %v", err)
+	}
+
+	stdout, stderr, err := env.run(t, "import", "vcf", vcfPath, "--track", "1m")
+	if err != nil {
+		t.Fatalf("frm import vcf failed: %v\nstderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Importing 2 contacts") || !strings.Contains(stdout, "Created: 1") || !strings.Contains(stdout, "Updated: 1") {
+		t.Errorf("unexpected output: %s", stdout)
+	}
+
+	dana := env.getContactCard("Dana Lee")
+	if dana == nil {
+		t.Fatal("expected Dana Lee to be created")
+	}
+	if dana.PreferredValue(vcard.FieldAddress) == "" || dana.PreferredValue(vcard.FieldTelephone) != "555-9876" {
+		t.Errorf("expected all fields kept, got %v", dana)
+	}
+	if getFrequency(dana) != "1m" {
+		t.Errorf("expected Dana tracked at 1m, got %q", getFrequency(dana))
+	}
+	if env.getContactCard("Muller Alice") != nil {
+		t.Error("Muller Alice should have matched Alice Müller")
+	}
+	alice := env.getContactCard("Alice Müller")
+	if alice.PreferredValue(vcard.FieldOrganization) != "Acme" || getFrequency(alice) != "1m" {
+		t.Errorf("expected Alice's org and frequency filled in, got %q / %q", alice.PreferredValue(vcard.FieldOrganization), getFrequency(alice))
+	}
+}
+
+func TestE2E_ImportWithUnreadableAccount(t *testing.T) {
+	env := setupTest(t)
+
+	// Dana may already live in the second account, which can't be read.
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	env.updateConfig(t, func(cfg *Config) {
+		cfg.Services = append(cfg.Services, ServiceConfig{Type: "carddav", Endpoint: dead.URL + "/", Username: "gone", Password: "test"})
+	})
+
+	vcfPath := filepath.Join(t.TempDir(), "contacts.vcf")
+	if err := os.WriteFile(vcfPath, []byte("BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Dana Lee\r\nEND:VCARD\r\n"), 0o644); err != nil {
+		t.Fatalf("writing vcf: %v", err)
+	}
+
+	_, stderr, err := env.run(t, "import", "vcf", vcfPath)
+	if err == nil {
+		t.Fatal("expected import to refuse while an account can't be read")
+	}
+	if !strings.Contains(stderr, "--allow-partial") {
+		t.Errorf("expected a hint about --allow-partial, got: %s", stderr)
+	}
+	if env.getContactCard("Dana Lee") != nil {
+		t.Error("Dana Lee should not have been created")
+	}
+
+	stdout, stderr, err := env.run(t, "import", "vcf", vcfPath, "--allow-partial")
+	if err != nil {
+		t.Fatalf("frm import vcf --allow-partial failed: %v\nstderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Created: 1") || env.getContactCard("Dana Lee") == nil {
+		t.Errorf("expected Dana Lee to be created, got: %s", stdout)
+	}
+}
+
+func TestE2E_ExportRestore(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContactFull("Alice", "2w", "alice@example.com", "", "")
+	env.backend.seedContact("Bob", "")
+
+	if _, _, err := env.run(t, "group", "set", "Alice", "friends"); err != nil {
+		t.Fatalf("frm group set failed: %v", err)
+	}
+	if _, _, err := env.run(t, "ignore", "Bob"); err != nil {
+		t.Fatalf("frm ignore failed: %v", err)
+	}
+	if _, _, err := env.run(t, "log", "Alice", "--note", "coffee", "--when", "2026-01-10"); err != nil {
+		t.Fatalf("frm log failed: %v", err)
+	}
+	if _, _, err := env.run(t, "log", "Bob", "--note", "lunch", "--when", "2026-01-12"); err != nil {
+		t.Fatalf("frm log failed: %v", err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "backup.json")
+	stdout, _, err := env.run(t, "export", "-o", archivePath)
+	if err != nil {
+		t.Fatalf("frm export failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Exported 2 contacts and 2 log entries") {
+		t.Errorf("unexpected export output: %s", stdout)
+	}
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatalf("reading archive: %v", err)
+	}
+	var archive struct {
+		Contacts []struct {
+			Name      string `json:"name"`
+			Frequency string `json:"frequency"`
+			Group     string `json:"group"`
+			VCard     string `json:"vcard"`
+		} `json:"contacts"`
+	}
+	if err := json.Unmarshal(data, &archive); err != nil {
+		t.Fatalf("parsing archive: %v", err)
+	}
+	if len(archive.Contacts) != 2 {
+		t.Fatalf("expected 2 contacts in archive, got %d", len(archive.Contacts))
+	}
+
+	// Lose the metadata and one log entry.
+	if _, _, err := env.run(t, "untrack", "Alice"); err != nil {
+		t.Fatalf("frm untrack failed: %v", err)
+	}
+	if _, _, err := env.run(t, "group", "unset", "Alice"); err != nil {
+		t.Fatalf("frm group unset failed: %v", err)
+	}
+	if _, _, err := env.run(t, "unignore", "Bob"); err != nil {
+		t.Fatalf("frm unignore failed: %v", err)
+	}
+	logPath := filepath.Join(env.configDir, "log.jsonl")
+	logData, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("reading log: %v", err)
+	}
+	lines := strings.SplitAfter(string(logData), "\n")
+	if err := os.WriteFile(logPath, []byte(lines[0]), 0o644); err != nil {
+		t.Fatalf("truncating log: %v", err)
+	}
+
+	// Dry run shows the diff without writing.
+	stdout, _, err = env.run(t, "restore", archivePath, "--dry-run")
+	if err != nil {
+		t.Fatalf("frm restore --dry-run failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Alice: frequency (none) -> 2w, group (none) -> friends") ||
+		!strings.Contains(stdout, "Bob: ignored false -> true") ||
+		!strings.Contains(stdout, "Would restore metadata on 2 contacts and add 1 log entries") {
+		t.Errorf("unexpected dry-run output: %s", stdout)
+	}
+	if getFrequency(env.getContactCard("Alice")) != "" {
+		t.Error("dry run should not change contacts")
+	}
+
+	if _, _, err := env.run(t, "restore", archivePath); err != nil {
+		t.Fatalf("frm restore failed: %v", err)
+	}
+	alice := env.getContactCard("Alice")
+	if getFrequency(alice) != "2w" || getGroup(alice) != "friends" {
+		t.Errorf("expected Alice restored to 2w/friends, got %q/%q", getFrequency(alice), getGroup(alice))
+	}
+	if !isIgnored(env.getContactCard("Bob")) {
+		t.Error("expected Bob to be ignored again")
+	}
+	logData, err = os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("reading log: %v", err)
+	}
+	if n := strings.Count(string(logData), "\n"); n != 2 {
+		t.Errorf("expected 2 log entries after restore, got %d", n)
+	}
+
+	// Restoring again changes nothing and adds no duplicates.
+	stdout, _, err = env.run(t, "restore", archivePath)
+	if err != nil {
+		t.Fatalf("second frm restore failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Restored metadata on 0 contacts and added 0 log entries") {
+		t.Errorf("expected no-op restore, got: %s", stdout)
+	}
+}
+
+func TestE2E_LogChannels(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "1d")
+
+	if _, _, err := env.run(t, "log", "Alice", "--via", "bogus channel"); err == nil {
+		t.Error("expected an error for an invalid channel")
+	}
+	if _, _, err := env.run(t, "log", "Alice", "--direction", "sideways"); err == nil {
+		t.Error("expected an error for an invalid direction")
+	}
+
+	if _, _, err := env.run(t, "log", "Alice", "--via", "phone", "--direction", "out", "--when", "2026-01-05"); err != nil {
+		t.Fatalf("frm log failed: %v", err)
+	}
+	if _, _, err := env.run(t, "log", "Alice", "--via", "meet", "--when", "2026-01-02"); err != nil {
+		t.Fatalf("frm log failed: %v", err)
+	}
+	stdout, _, err := env.run(t, "log", "Alice", "--note", "coffee", "--via", "in-person",
+		"--duration", "1h30m", "--location", "Blue Bottle", "--when", "2026-01-10", "--json")
+	if err != nil {
+		t.Fatalf("frm log failed: %v", err)
+	}
+	if !strings.Contains(stdout, `"duration_min": 90`) {
+		t.Errorf("expected duration in JSON, got: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "history", "Alice")
+	if err != nil {
+		t.Fatalf("frm history failed: %v", err)
+	}
+	if !strings.Contains(stdout, "2026-01-02  (meeting)") ||
+		!strings.Contains(stdout, "2026-01-05  (call, outgoing)") ||
+		!strings.Contains(stdout, "2026-01-10  (in-person, 1h30m, at Blue Bottle)  coffee") {
+		t.Errorf("unexpected history: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "context", "Alice")
+	if err != nil {
+		t.Fatalf("frm context failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Last via:  in-person, 1h30m, at Blue Bottle") {
+		t.Errorf("expected channel in context, got: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "check", "--json")
+	if err != nil {
+		t.Fatalf("frm check --json failed: %v", err)
+	}
+	var check struct {
+		Contacts []overdueContact `json:"contacts"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &check); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, stdout)
+	}
+	if overdue := check.Contacts; len(overdue) != 1 || overdue[0].LastVia != "in-person" || overdue[0].LastLocation != "Blue Bottle" || overdue[0].LastDurationMin != 90 {
+		t.Errorf("unexpected check output: %+v", overdue)
+	}
+
+	stdout, _, err = env.run(t, "stats", "--json")
+	if err != nil {
+		t.Fatalf("frm stats --json failed: %v", err)
+	}
+	var stats struct {
+		ByChannel map[string]int `json:"by_channel"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &stats); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, stdout)
+	}
+	if stats.ByChannel["call"] != 1 || stats.ByChannel["in-person"] != 1 || stats.ByChannel["meeting"] != 1 {
+		t.Errorf("unexpected channel breakdown: %v", stats.ByChannel)
+	}
+	stdout, _, err = env.run(t, "stats")
+	if err != nil {
+		t.Fatalf("frm stats failed: %v", err)
+	}
+	if !strings.Contains(stdout, "By channel:") {
+		t.Errorf("expected channel breakdown in stats, got: %s", stdout)
+	}
+}
+
+func TestE2E_LogEditRemoveMove(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "2w")
+	env.backend.seedContact("Bob", "2w")
+
+	// An entry written before IDs existed still gets a stable one.
+	logPath := filepath.Join(env.configDir, "log.jsonl")
+	legacy := `{"contact":"Alice","time":"2026-01-02T00:00:00Z","note":"old"}` + "\n" + "not json\n"
+	if err := os.WriteFile(logPath, []byte(legacy), 0o644); err != nil {
+		t.Fatalf("writing log: %v", err)
+	}
+
+	// Concurrent logs don't corrupt the file.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := env.run(t, "log", "Alice", "--note", fmt.Sprintf("note %d", i), "--when", "2026-01-10"); err != nil {
+				t.Errorf("frm log failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stdout, _, err := env.run(t, "history", "Alice", "--json")
+	if err != nil {
+		t.Fatalf("frm history failed: %v", err)
+	}
+	var entries []LogEntry
+	if err := json.Unmarshal([]byte(stdout), &entries); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, stdout)
+	}
+	if len(entries) != 9 {
+		t.Fatalf("expected 9 entries, got %d", len(entries))
+	}
+	ids := make(map[string]bool)
+	for _, e := range entries {
+		if e.ID == "" || ids[e.ID] {
+			t.Fatalf("expected unique IDs, got %q", e.ID)
+		}
+		ids[e.ID] = true
+	}
+	legacyID := entries[0].ID
+
+	// Edit the legacy entry by a short prefix.
+	stdout, _, err = env.run(t, "log", "edit", legacyID[:6], "--note", "fixed typo", "--via", "call")
+	if err != nil {
+		t.Fatalf("frm log edit failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Updated log entry") {
+		t.Errorf("unexpected edit output: %s", stdout)
+	}
+	stdout, _, _ = env.run(t, "history", "Alice")
+	if !strings.Contains(stdout, legacyID[:8]+"  2026-01-02  (call)  fixed typo") {
+		t.Errorf("expected edited entry in history, got: %s", stdout)
+	}
+
+	// Move one entry to Bob, then remove another.
+	if _, _, err := env.run(t, "log", "move", entries[1].ID, "bob"); err != nil {
+		t.Fatalf("frm log move failed: %v", err)
+	}
+	stdout, _, _ = env.run(t, "history", "Bob")
+	if !strings.Contains(stdout, entries[1].Note) {
+		t.Errorf("expected moved entry in Bob's history, got: %s", stdout)
+	}
+	if _, _, err := env.run(t, "log", "rm", entries[2].ID, "--dry-run"); err != nil {
+		t.Fatalf("frm log rm --dry-run failed: %v", err)
+	}
+	if _, _, err := env.run(t, "log", "rm", entries[2].ID); err != nil {
+		t.Fatalf("frm log rm failed: %v", err)
+	}
+	if _, _, err := env.run(t, "log", "rm", entries[2].ID); err == nil {
+		t.Error("expected removing a missing entry to fail")
+	}
+
+	stdout, _, _ = env.run(t, "history", "Alice", "--json")
+	entries = nil
+	if err := json.Unmarshal([]byte(stdout), &entries); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, stdout)
+	}
+	if len(entries) != 7 {
+		t.Errorf("expected 7 entries for Alice, got %d", len(entries))
+	}
+	if entries[0].ID != legacyID {
+		t.Errorf("expected legacy ID to stay stable, got %q want %q", entries[0].ID, legacyID)
+	}
+
+	// Malformed lines survive a rewrite.
+	data, _ := os.ReadFile(logPath)
+	if !strings.Contains(string(data), "not json\n") {
+		t.Error("expected malformed line to be preserved")
+	}
+}
+
+func TestE2E_LogVerifyRepair(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "2w")
+
+	// A torn final line, as left by a crash mid-write, plus a line without a contact.
+	logPath := filepath.Join(env.configDir, "log.jsonl")
+	data := `{"id":"aaaa1111","contact":"Alice","time":"2026-01-02T00:00:00Z","note":"ok"}` + "\n" +
+		`{"id":"bbbb2222","time":"2026-01-03T00:00:00Z"}` + "\n" +
+		`{"id":"cccc3333","contact":"Ali`
+	if err := os.WriteFile(logPath, []byte(data), 0o644); err != nil {
+		t.Fatalf("writing log: %v", err)
+	}
+
+	// Appending after a torn line starts a fresh line.
+	if _, _, err := env.run(t, "log", "Alice", "--note", "after crash"); err != nil {
+		t.Fatalf("frm log failed: %v", err)
+	}
+	stdout, stderr, err := env.run(t, "history", "Alice")
+	if err != nil {
+		t.Fatalf("frm history failed: %v", err)
+	}
+	if !strings.Contains(stdout, "after crash") || !strings.Contains(stdout, "ok") {
+		t.Errorf("expected both valid entries, got: %s", stdout)
+	}
+	if !strings.Contains(stderr, "skipped 2 malformed line(s)") {
+		t.Errorf("expected a warning about malformed lines, got: %s", stderr)
+	}
+
+	stdout, _, err = env.run(t, "log", "verify")
+	if err == nil {
+		t.Error("expected frm log verify to fail")
+	}
+	if !strings.Contains(stdout, "2 entries, 2 malformed lines") || !strings.Contains(stdout, "line 2: missing contact") {
+		t.Errorf("unexpected verify output: %s", stdout)
+	}
+
+	if _, _, err := env.run(t, "log", "repair", "--dry-run"); err != nil {
+		t.Fatalf("frm log repair --dry-run failed: %v", err)
+	}
+	if _, err := os.Stat(logPath + ".quarantine"); !os.IsNotExist(err) {
+		t.Error("dry run should not write a quarantine file")
+	}
+
+	stdout, _, err = env.run(t, "log", "repair")
+	if err != nil {
+		t.Fatalf("frm log repair failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Quarantined 2 lines") {
+		t.Errorf("unexpected repair output: %s", stdout)
+	}
+	quarantined, err := os.ReadFile(logPath + ".quarantine")
+	if err != nil {
+		t.Fatalf("reading quarantine: %v", err)
+	}
+	if !strings.Contains(string(quarantined), "bbbb2222") || !strings.Contains(string(quarantined), `"contact":"Ali`) {
+		t.Errorf("expected bad lines in quarantine, got: %s", quarantined)
+	}
+
+	stdout, _, err = env.run(t, "log", "verify", "--json")
+	if err != nil {
+		t.Fatalf("frm log verify --json failed: %v", err)
+	}
+	var result struct {
+		OK      bool `json:"ok"`
+		Entries int  `json:"entries"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, stdout)
+	}
+	if !result.OK || result.Entries != 2 {
+		t.Errorf("expected a clean log with 2 entries, got %+v", result)
+	}
+}
+
+func TestE2E_Next(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "1w")
+	env.backend.seedContact("Bob", "1m")
+	env.backend.seedContact("Carol", "1m")
+	env.backend.seedContact("Dave", "1m")
+	env.backend.seedContact("Eve", "")
+	env.backend.seedContact("Frank", "1w")
+	env.backend.seedContact("Gus", "1w")
+	env.updateConfig(t, func(cfg *Config) {
+		cfg.Groups = map[string]GroupConfig{"Family": {Weight: 3}}
+	})
+
+	today := dateOnly(time.Now())
+	ago := func(days int) string { return today.AddDate(0, 0, -days).Format("2006-01-02") }
+	for _, args := range [][]string{
+		{"log", "Alice", "--when", ago(21)},
+		{"log", "Bob", "--when", ago(60)},
+		{"log", "Carol", "--when", ago(15)},
+		{"log", "Dave", "--when", ago(3)},
+		{"group", "set", "Carol", "family"},
+		{"edit", "Dave", "--birthday", today.AddDate(0, 0, 1).Format("01-02")},
+		{"ignore", "Frank"},
+		{"snooze", "Gus", "--until", "2m"},
+	} {
+		if _, stderr, err := env.run(t, args...); err != nil {
+			t.Fatalf("frm %v failed: %v\nstderr: %s", args, err, stderr)
+		}
+	}
+
+	stdout, _, err := env.run(t, "next")
+	if err != nil {
+		t.Fatalf("frm next failed: %v", err)
+	}
+	if !strings.HasPrefix(stdout, "Alice (score 3.0") || !strings.Contains(stdout, "overdue: last contact 3w ago, every 1w") || strings.Contains(stdout, "Bob") {
+		t.Errorf("expected a single suggestion for Alice, got: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "next", "-n", "10", "--json")
+	if err != nil {
+		t.Fatalf("frm next --json failed: %v", err)
+	}
+	var got []suggestion
+	if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout)
+	}
+	var names []string
+	for _, s := range got {
+		names = append(names, s.Name)
+	}
+	// Alice is 3x overdue, Bob 2x; Carol is half due (of a calendar month,
+	// 28 to 31 days) but weighted 3x by her group; Dave was just seen but has
+	// a birthday tomorrow.
+	if strings.Join(names, ",") != "Alice,Bob,Carol,Dave" {
+		t.Fatalf("expected Alice,Bob,Carol,Dave, got %v", names)
+	}
+	carol, dave := got[2], got[3]
+	if carol.Breakdown.GroupWeight != 3 || carol.Breakdown.Overdue < 0.48 || carol.Breakdown.Overdue > 0.54 || !strings.Contains(carol.Reason, "family group weighted 3x") {
+		t.Errorf("unexpected Carol suggestion: %+v", carol)
+	}
+	if dave.Breakdown.Upcoming != 0.93 || !strings.Contains(dave.Reason, "birthday tomorrow") {
+		t.Errorf("unexpected Dave suggestion: %+v", dave)
+	}
+	for _, s := range got {
+		b := s.Breakdown
+		if want := round2(b.Overdue*b.GroupWeight + b.Recency + b.Upcoming); math.Abs(s.Score-want) > 0.02 {
+			t.Errorf("%s: score %v doesn't match breakdown %+v", s.Name, s.Score, b)
+		}
+	}
+}
+
+func TestE2E_Upcoming(t *testing.T) {
+	env := setupTest(t)
+	env.backend.seedContact("Alice", "1w")
+	env.backend.seedContact("Bob", "")
+	env.backend.seedContact("Carol", "")
+
+	today := dateOnly(time.Now())
+	soon := today.AddDate(0, 0, 3)
+	later := today.AddDate(0, 0, 10)
+	far := today.AddDate(0, 0, 60)
+	// A year-less anniversary in vCard 4.0 basic format, as other clients write it.
+	env.backend.mu.Lock()
+	env.backend.contacts[abPath+"bob.vcf"].Card[vcard.FieldAnniversary] = []*vcard.Field{{Value: later.Format("--0102")}}
+	env.backend.mu.Unlock()
+
+	if _, _, err := env.run(t, "edit", "Alice", "--birthday", fmt.Sprintf("1990-%s", soon.Format("01-02"))); err != nil {
+		t.Fatalf("frm edit --birthday failed: %v", err)
+	}
+	if _, _, err := env.run(t, "edit", "Carol", "--birthday", far.Format("01-02")); err != nil {
+		t.Fatalf("frm edit --birthday failed: %v", err)
+	}
+	// vCard 3.0 has no year-less dates, so Apple's 1604 convention is used.
+	bday := env.getContactCard("Carol")[vcard.FieldBirthday][0]
+	if bday.Value != "1604-"+far.Format("01-02") || bday.Params.Get("X-APPLE-OMIT-YEAR") != "1604" {
+		t.Errorf("expected a 1604 BDAY with X-APPLE-OMIT-YEAR, got %q %v", bday.Value, bday.Params)
+	}
+
+	stdout, _, err := env.run(t, "upcoming", "--within", "30d")
+	if err != nil {
+		t.Fatalf("frm upcoming failed: %v", err)
+	}
+	want := fmt.Sprintf("Alice: birthday, turning %d (in 3 days)", soon.Year()-1990)
+	if !strings.Contains(stdout, want) || !strings.Contains(stdout, "Bob: anniversary (in 10 days)") {
+		t.Errorf("unexpected upcoming output: %s", stdout)
+	}
+	if strings.Contains(stdout, "Carol") {
+		t.Errorf("Carol's birthday is outside the window: %s", stdout)
+	}
+	if strings.Index(stdout, "Alice") > strings.Index(stdout, "Bob") {
+		t.Errorf("expected soonest first: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "upcoming", "--within", "3m", "--json")
+	if err != nil {
+		t.Fatalf("frm upcoming --json failed: %v", err)
+	}
+	var events []upcomingEvent
+	if err := json.Unmarshal([]byte(stdout), &events); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, stdout)
+	}
+	if len(events) != 3 || events[2].Name != "Carol" || events[2].DaysUntil != 60 || events[2].Years != 0 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	// check lists the week ahead after the overdue contacts.
+	stdout, _, err = env.run(t, "check")
+	if err != nil {
+		t.Fatalf("frm check failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Coming up in the next 7d:") || !strings.Contains(stdout, "Alice: birthday") || strings.Contains(stdout, "Bob: anniversary") {
+		t.Errorf("unexpected check output: %s", stdout)
+	}
+	// JSON lists events for everyone, not only the contacts that are due:
+	// Bob is untracked.
+	stdout, _, err = env.run(t, "check", "--upcoming", "2w", "--json")
+	if err != nil {
+		t.Fatalf("frm check --json failed: %v", err)
+	}
+	var check struct {
+		Contacts []overdueContact `json:"contacts"`
+		Upcoming []upcomingEvent  `json:"upcoming"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &check); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, stdout)
+	}
+	if len(check.Contacts) != 1 || check.Contacts[0].Name != "Alice" {
+		t.Errorf("expected only Alice due, got: %s", stdout)
+	}
+	if len(check.Upcoming) != 2 || check.Upcoming[0].Name != "Alice" || check.Upcoming[0].DaysUntil != 3 ||
+		check.Upcoming[1].Name != "Bob" || check.Upcoming[1].Kind != "anniversary" {
+		t.Errorf("expected Alice's birthday and Bob's anniversary in check --json, got: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "context", "Alice", "--json")
+	if err != nil {
+		t.Fatalf("frm context --json failed: %v", err)
+	}
+	var ctx map[string]any
+	if err := json.Unmarshal([]byte(stdout), &ctx); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, stdout)
+	}
+	if ctx["birthday"] != fmt.Sprintf("1990-%s", soon.Format("01-02")) || ctx["upcoming"] == nil {
+		t.Errorf("unexpected context: %v", ctx)
+	}
+
+	if _, _, err := env.run(t, "edit", "Alice", "--birthday", ""); err != nil {
+		t.Fatalf("clearing birthday failed: %v", err)
+	}
+	if got := env.getContactCard("Alice").Value(vcard.FieldBirthday); got != "" {
+		t.Errorf("expected BDAY cleared, got %q", got)
+	}
+	if _, _, err := env.run(t, "edit", "Alice", "--birthday", "1990-02-30"); err == nil {
+		t.Error("expected an invalid date to be rejected")
+	}
+
+	// vCard 4.0 cards get the standard year-less form.
+	env.backend.mu.Lock()
+	alice := env.backend.contacts[abPath+"alice.vcf"]
+	alice.Card["VERSION"] = []*vcard.Field{{Value: "4.0"}}
+	alice.ETag += "-v4"
+	env.backend.contacts[abPath+"alice.vcf"] = alice
+	env.backend.mu.Unlock()
+	if _, _, err := env.run(t, "edit", "Alice", "--birthday", "04-12"); err != nil {
+		t.Fatalf("frm edit --birthday failed: %v", err)
+	}
+	if got := env.getContactCard("Alice").Value(vcard.FieldBirthday); got != "--0412" {
+		t.Errorf("expected --0412 on a vCard 4.0 card, got %q", got)
+	}
+
+	// Year-less Feb 29 falls on Feb 28 outside leap years.
+	leap, _ := parseCardDate("--0229")
+	if got := leap.next(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)); got.Format("2006-01-02") != "2027-02-28" {
+		t.Errorf("expected Feb 28 in 2027, got %s", got)
+	}
+	if got := leap.next(time.Date(2028, 1, 1, 0, 0, 0, 0, time.UTC)); got.Format("2006-01-02") != "2028-02-29" {
+		t.Errorf("expected Feb 29 in 2028, got %s", got)
+	}
+}
+
+func TestE2E_SyncMail(t *testing.T) {
+	ago := func(days int) string {
+		return time.Now().UTC().Add(-time.Duration(days) * 24 * time.Hour).Format(time.RFC3339)
+	}
+	messages := map[string][]mockMessage{
+		"alice@example.com": {
+			{Subject: "Lunch?", ReceivedAt: ago(5), MessageID: "<m1@example.com>", From: "alice@example.com", To: []string{"me@example.com"}},
+			{Subject: "Re: Lunch?", ReceivedAt: ago(4), MessageID: "<m2@example.com>", From: "me@example.com", To: []string{"alice@example.com", "bob@example.com"}},
+			{Subject: "Old news", ReceivedAt: ago(60), MessageID: "<m0@example.com>", From: "alice@example.com", To: []string{"me@example.com"}},
+		},
+		"news@example.com": {
+			{Subject: "Weekly digest", ReceivedAt: ago(3), MessageID: "<m3@example.com>", From: "news@example.com", To: []string{"me@example.com"}},
+		},
+	}
+	env := setupTestWithJMAP(t, messages)
+	env.backend.seedContactWithEmail("Alice", "2w", "alice@example.com")
+	env.backend.seedContactWithEmail("Bob", "", "BOB@example.com")
+
+	stdout, _, err := env.run(t, "sync-mail", "--dry-run")
+	if err != nil {
+		t.Fatalf("frm sync-mail --dry-run failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Would log 3 emails from 3 messages scanned") {
+		t.Errorf("unexpected dry-run output: %s", stdout)
+	}
+	if _, err := os.Stat(filepath.Join(env.configDir, "log.jsonl")); !os.IsNotExist(err) {
+		t.Error("dry run should not write the log")
+	}
+
+	stdout, _, err = env.run(t, "sync-mail", "--json")
+	if err != nil {
+		t.Fatalf("frm sync-mail failed: %v", err)
+	}
+	var result struct {
+		Scanned int        `json:"scanned"`
+		Logged  []LogEntry `json:"logged"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, stdout)
+	}
+	got := map[string]string{}
+	for _, e := range result.Logged {
+		if e.Via != "email" {
+			t.Errorf("expected via=email, got %+v", e)
+		}
+		got[e.Contact+" "+e.MessageID] = e.Direction + " " + e.Note
+	}
+	want := map[string]string{
+		"Alice <m1@example.com>": "in Lunch?",
+		"Alice <m2@example.com>": "out Re: Lunch?",
+		"Bob <m2@example.com>":   "out Re: Lunch?",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("logged %v, want %v", got, want)
+	}
+
+	// Alice is no longer overdue.
+	stdout, _, _ = env.run(t, "check")
+	if strings.Contains(stdout, "Alice") {
+		t.Errorf("Alice should be caught up after sync-mail: %s", stdout)
+	}
+
+	// A second run starts at the high-water mark and logs nothing new.
+	stdout, _, err = env.run(t, "sync-mail")
+	if err != nil {
+		t.Fatalf("second frm sync-mail failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Logged 0 emails") {
+		t.Errorf("expected nothing new, got: %s", stdout)
+	}
+
+	// Reaching further back picks up only the older message.
+	stdout, _, err = env.run(t, "sync-mail", "--since", "90d")
+	if err != nil {
+		t.Fatalf("frm sync-mail --since failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Old news") || !strings.Contains(stdout, "Logged 1 emails from 4 messages scanned") {
+		t.Errorf("unexpected --since output: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "history", "Alice")
+	if err != nil {
+		t.Fatalf("frm history failed: %v", err)
+	}
+	if strings.Count(stdout, "email, ") != 3 {
+		t.Errorf("expected 3 email entries for Alice, got: %s", stdout)
+	}
+}
+
+// startIMAPServer serves an in-memory mailbox (user "username", password
+// "password") with the given messages, keyed by mailbox name.
+// lockedBuffer is a bytes.Buffer safe for concurrent writes.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// startIMAPServer serves mailboxes over IMAP. The returned buffer records the
+// protocol traffic.
+func startIMAPServer(t *testing.T, mailboxes map[string][]string) (host string, port int, traffic *lockedBuffer) {
+	t.Helper()
+	be := memory.New()
+	user, err := be.Login(nil, "username", "password")
+	if err != nil {
+		t.Fatalf("imap login: %v", err)
+	}
+	for name, msgs := range mailboxes {
+		if name != "INBOX" {
+			if err := user.CreateMailbox(name); err != nil {
+				t.Fatalf("creating mailbox %s: %v", name, err)
+			}
+		}
+		mbox, _ := user.GetMailbox(name)
+		for _, msg := range msgs {
+			body := strings.ReplaceAll(msg, "\n", "\r\n")
+			if err := mbox.CreateMessage(nil, time.Now(), bytes.NewBufferString(body)); err != nil {
+				t.Fatalf("creating message: %v", err)
+			}
+		}
+	}
+
+	srv := imapserver.New(be)
+	srv.AllowInsecureAuth = true
+	traffic = &lockedBuffer{}
+	srv.Debug = traffic
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	addr := l.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, traffic
+}
+
+func TestE2E_ContextWithIMAP(t *testing.T) {
+	host, port, traffic := startIMAPServer(t, map[string][]string{
+		"INBOX": {
+			"From: Alice <alice@example.com>\nTo: me@example.com\nSubject: Dinner Friday?\nDate: Sun, 10 Mar 2024 18:00:00 +0000\n\nAre you free?",
+			"From: bob@example.com\nTo: me@example.com\nSubject: Unrelated\nDate: Mon, 11 Mar 2024 09:00:00 +0000\n\nHi",
+		},
+		"Sent": {
+			"From: me@example.com\nTo: alice@example.com\nSubject: Re: Dinner Friday?\nDate: Mon, 11 Mar 2024 08:00:00 +0000\n\nYes!",
+		},
+	})
+	env := setupTest(t)
+	env.addService(t, ServiceConfig{
+		Type:      "imap",
+		Host:      host,
+		Port:      port,
+		TLS:       "none",
+		Username:  "username",
+		Password:  "password",
+		Mailboxes: []string{"INBOX", "Sent"},
+	})
+	env.backend.seedContactWithEmail("Alice", "2w", "alice@example.com")
+
+	stdout, stderr, err := env.run(t, "context", "Alice")
+	if err != nil {
+		t.Fatalf("frm context failed: %v\nstderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Recent emails:") || strings.Contains(stdout, "Unrelated") {
+		t.Errorf("unexpected context output: %s", stdout)
+	}
+	reply := strings.Index(stdout, "Re: Dinner Friday? (2024-03-11)")
+	first := strings.Index(stdout, "  Dinner Friday? (2024-03-10)")
+	if reply < 0 || first < 0 || reply > first {
+		t.Errorf("expected both messages, newest first, got: %s", stdout)
+	}
+	if !strings.Contains(traffic.String(), "LOGOUT") {
+		t.Errorf("expected the connection to be logged out, got traffic: %s", traffic.String())
+	}
+
+	// A bad password is reported but doesn't break context.
+	env.addService(t, ServiceConfig{Type: "imap", Host: host, Port: port, TLS: "none", Username: "username", Password: "wrong"})
+	stdout, _, err = env.run(t, "context", "Alice")
+	if err != nil {
+		t.Fatalf("frm context failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Recent emails:\n  error: username (127.0.0.1): logging in") || !strings.Contains(stdout, "Dinner Friday?") {
+		t.Errorf("expected a login error alongside results, got: %s", stdout)
+	}
+}
+
+func TestE2E_ContextWithLocalMail(t *testing.T) {
+	env := setupTest(t)
+	maildir := filepath.Join(t.TempDir(), "Mail")
+	for _, sub := range []string{"INBOX/cur", "INBOX/new", "INBOX/tmp", "Sent/cur"} {
+		if err := os.MkdirAll(filepath.Join(maildir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeMsg := func(path, msg string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(msg), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeMsg(filepath.Join(maildir, "INBOX/cur/1.host:2,S"), "From: Alice <alice@example.com>\nTo: me@example.com\nSubject: =?UTF-8?Q?Caf=C3=A9_plans?=\nDate: Sun, 10 Mar 2024 18:00:00 +0000\n\nCoffee?")
+	writeMsg(filepath.Join(maildir, "INBOX/new/2.host"), "From: bob@example.com\nTo: me@example.com\nSubject: Unrelated\nDate: Mon, 11 Mar 2024 09:00:00 +0000\n\nHi")
+	writeMsg(filepath.Join(maildir, "Sent/cur/3.host:2,S"), "From: me@example.com\nTo: Someone Else <x@example.com>, ALICE@example.com\nSubject: Re: Café plans\nDate: Mon, 11 Mar 2024 08:00:00 +0000\n\nSure")
+	mbox := filepath.Join(t.TempDir(), "archive.mbox")
+	writeMsg(mbox, "From alice@example.com Sat Jan  6 10:00:00 2024\nFrom: alice@example.com\nTo: me@example.com\nSubject: Old thread\nDate: Sat, 6 Jan 2024 10:00:00 +0000\n\nFrom the archive.\n\nFrom bob@example.com Sun Jan  7 10:00:00 2024\nFrom: bob@example.com\nSubject: Bob only\nDate: Sun, 7 Jan 2024 10:00:00 +0000\n\nHi\n")
+
+	env.addService(t, ServiceConfig{Type: "maildir", Path: maildir, MaxResults: 5})
+	env.addService(t, ServiceConfig{Type: "mbox", Path: mbox})
+	env.backend.seedContactWithEmail("Alice", "2w", "alice@example.com")
+
+	stdout, stderr, err := env.run(t, "context", "Alice")
+	if err != nil {
+		t.Fatalf("frm context failed: %v\nstderr: %s", err, stderr)
+	}
+	if strings.Contains(stdout, "Unrelated") || strings.Contains(stdout, "Bob only") {
+		t.Errorf("context included another contact's mail: %s", stdout)
+	}
+	reply := strings.Index(stdout, "Re: Café plans (2024-03-11)")
+	first := strings.Index(stdout, "  Café plans (2024-03-10)")
+	if reply < 0 || first < 0 || reply > first {
+		t.Errorf("expected both maildir messages, newest first, got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "Old thread (2024-01-06)") {
+		t.Errorf("expected the mbox message, got: %s", stdout)
+	}
+
+	indexes, _ := filepath.Glob(filepath.Join(env.configDir, "cache", "maildir-*.json"))
+	if len(indexes) != 1 {
+		t.Fatalf("expected a persisted maildir index, got %v", indexes)
+	}
+
+	// Removed messages drop out of the index on the next run.
+	if err := os.Remove(filepath.Join(maildir, "Sent/cur/3.host:2,S")); err != nil {
+		t.Fatal(err)
+	}
+	stdout, _, err = env.run(t, "context", "Alice")
+	if err != nil {
+		t.Fatalf("frm context failed: %v", err)
+	}
+	if strings.Contains(stdout, "Re: Café plans") || !strings.Contains(stdout, "Café plans (2024-03-10)") {
+		t.Errorf("expected the removed message to be gone, got: %s", stdout)
+	}
+
+	// A message that can't be opened, like one moved between the scan and
+	// the read, is skipped rather than failing the whole maildir.
+	if err := os.Symlink(filepath.Join(maildir, "gone"), filepath.Join(maildir, "INBOX/cur/4.host:2,S")); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, err = env.run(t, "context", "Alice")
+	if err != nil {
+		t.Fatalf("frm context failed: %v\nstderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Café plans (2024-03-10)") || strings.Contains(stdout, "error:") {
+		t.Errorf("expected the readable messages despite the broken one, got: %s", stdout)
+	}
+	if !strings.Contains(stderr, "warning: skipping "+filepath.Join(maildir, "INBOX/cur/4.host:2,S")) {
+		t.Errorf("expected a warning about the unreadable message, got: %s", stderr)
+	}
+
+	// A missing path is reported but doesn't break context.
+	env.addService(t, ServiceConfig{Type: "maildir", Path: filepath.Join(maildir, "missing")})
+	stdout, _, err = env.run(t, "context", "Alice")
+	if err != nil {
+		t.Fatalf("frm context failed: %v", err)
+	}
+	if !strings.Contains(stdout, "  error: "+filepath.Join(maildir, "missing")+": scanning") || !strings.Contains(stdout, "Old thread") {
+		t.Errorf("expected a scan error alongside results, got: %s", stdout)
+	}
+}
+
+func TestE2E_ContextWithExec(t *testing.T) {
+	env := setupTest(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "notes.sh")
+	stdin := filepath.Join(dir, "stdin.json")
+	os.WriteFile(script, []byte(`#!/bin/sh
+cat > "`+stdin+`"
+echo '{"title": "Met at PyCon", "time": "2024-05-17", "snippet": "Works on compilers", "url": "obsidian://open?file=alice"}'
+echo
+echo '"Owes me a book"'
+`), 0o755)
+	env.addService(t, ServiceConfig{Type: "exec", Command: []string{script, "--flag"}, Name: "Notes"})
+	env.backend.seedContactFull("Alice", "2w", "Alice@Example.com", "555-0100", "Acme")
+	if _, stderr, err := env.run(t, "group", "set", "Alice", "friends"); err != nil {
+		t.Fatalf("frm group set failed: %v\nstderr: %s", err, stderr)
+	}
+
+	stdout, stderr, err := env.run(t, "context", "Alice")
+	if err != nil {
+		t.Fatalf("frm context failed: %v\nstderr: %s", err, stderr)
+	}
+	want := "Notes:\n  Met at PyCon (2024-05-17)\n    Works on compilers\n    obsidian://open?file=alice\n  Owes me a book\n"
+	if !strings.Contains(stdout, want) {
+		t.Errorf("expected exec context %q, got: %s", want, stdout)
+	}
+
+	data, err := os.ReadFile(stdin)
+	if err != nil {
+		t.Fatalf("command did not receive stdin: %v", err)
+	}
+	var got execContact
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("stdin is not JSON: %v\n%s", err, data)
+	}
+	if got.Name != "Alice" || got.Org != "Acme" || got.Path == "" ||
+		len(got.Emails) != 1 || got.Emails[0] != "alice@example.com" ||
+		len(got.Phones) != 1 || got.Phones[0] != "555-0100" ||
+		got.Fields["X-FRM-FREQUENCY"] != "2w" || got.Fields["X-FRM-GROUP"] != "friends" {
+		t.Errorf("unexpected contact on stdin: %s", data)
+	}
+
+	// Failures, bad output and slow commands are reported without breaking context.
+	bad := filepath.Join(dir, "bad.sh")
+	os.WriteFile(bad, []byte("#!/bin/sh\necho 'not json'\n"), 0o755)
+	slow := filepath.Join(dir, "slow.sh")
+	os.WriteFile(slow, []byte("#!/bin/sh\nsleep 5\n"), 0o755)
+	failing := filepath.Join(dir, "failing.sh")
+	os.WriteFile(failing, []byte("#!/bin/sh\necho 'index missing' >&2\nexit 3\n"), 0o755)
+	env.addService(t, ServiceConfig{Type: "exec", Command: []string{bad}})
+	env.addService(t, ServiceConfig{Type: "exec", Command: []string{slow}, Timeout: "200ms"})
+	env.addService(t, ServiceConfig{Type: "exec", Command: []string{failing}})
+	stdout, _, err = env.run(t, "context", "Alice")
+	if err != nil {
+		t.Fatalf("frm context failed: %v", err)
+	}
+	for _, msg := range []string{"bad.sh:\n  error: output line 1", "slow.sh:\n  error: timed out after 200ms", "failing.sh:\n  error: exit status 3: index missing"} {
+		if !strings.Contains(stdout, msg) {
+			t.Errorf("expected %q in output, got: %s", msg, stdout)
+		}
+	}
+	if !strings.Contains(stdout, "Met at PyCon") {
+		t.Errorf("expected the working provider's context, got: %s", stdout)
+	}
+
+	// --json groups structured items by provider, with errors alongside.
+	stdout, _, err = env.run(t, "context", "Alice", "--json")
+	if err != nil {
+		t.Fatalf("frm context --json failed: %v", err)
+	}
+	var result struct {
+		Providers []providerContext `json:"providers"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout)
+	}
+	if len(result.Providers) != 4 {
+		t.Fatalf("expected 4 provider groups, got: %s", stdout)
+	}
+	notes := result.Providers[0]
+	if notes.Provider != "Notes" || notes.Error != "" || len(notes.Items) != 2 {
+		t.Fatalf("unexpected Notes group: %+v", notes)
+	}
+	item := notes.Items[0]
+	if item.Provider != "Notes" || item.Kind != "note" || item.Title != "Met at PyCon" ||
+		item.Time.Format("2006-01-02") != "2024-05-17" || item.URL != "obsidian://open?file=alice" || item.Snippet != "Works on compilers" {
+		t.Errorf("unexpected item: %+v", item)
+	}
+	if !strings.Contains(stdout, `"time"`) || strings.Count(stdout, `"time"`) != 1 {
+		t.Errorf("expected only the dated item to carry a time: %s", stdout)
+	}
+	if g := result.Providers[2]; g.Provider != "slow.sh" || !strings.Contains(g.Error, "timed out") || len(g.Items) != 0 {
+		t.Errorf("expected the timeout as the slow.sh group's error, got: %+v", g)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Mock CalDAV server
+// ---------------------------------------------------------------------------
+
+type mockEvent struct {
+	UID, Summary, Location string
+	Start                  time.Time
+	Duration               time.Duration
+	Organizer              string
+	Attendees              map[string]string // address -> PARTSTAT
+}
+
+func (e mockEvent) ics() string {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\n")
+	fmt.Fprintf(&b, "UID:%s\r\nSUMMARY:%s\r\n", e.UID, e.Summary)
+	fmt.Fprintf(&b, "DTSTART:%s\r\n", e.Start.UTC().Format("20060102T150405Z"))
+	fmt.Fprintf(&b, "DTEND:%s\r\n", e.Start.Add(e.Duration).UTC().Format("20060102T150405Z"))
+	if e.Location != "" {
+		fmt.Fprintf(&b, "LOCATION:%s\r\n", e.Location)
+	}
+	if e.Organizer != "" {
+		fmt.Fprintf(&b, "ORGANIZER:mailto:%s\r\n", e.Organizer)
+	}
+	for addr, partstat := range e.Attendees {
+		// Long lines are folded, as real servers do.
+		fmt.Fprintf(&b, "ATTENDEE;CN=\"Someone: Else\";PARTSTAT=%s;\r\n ROLE=REQ-PARTICIPANT:mailto:%s\r\n", partstat, addr)
+	}
+	b.WriteString("END:VEVENT\r\nEND:VCALENDAR\r\n")
+	return b.String()
+}
+
+// newMockCalDAVServer serves one calendar at /cal/home/work/, discoverable
+// from /cal/ via current-user-principal and calendar-home-set. REPORTs honour
+// the time-range start and end.
+func newMockCalDAVServer(events []mockEvent) *httptest.Server {
+	multistatus := func(w http.ResponseWriter, responses string) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">%s</d:multistatus>`, responses)
+	}
+	prop := func(href, props string) string {
+		return fmt.Sprintf(`<d:response><d:href>%s</d:href><d:propstat><d:prop>%s</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, href, props)
+	}
+	timeRange := regexp.MustCompile(`time-range start="(\w+)" end="(\w+)"`)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, _ := r.BasicAuth(); u != "test" || p != "test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.Method == "PROPFIND" && r.URL.Path == "/cal/":
+			multistatus(w, prop("/cal/", `<d:current-user-principal><d:href>/cal/principal/</d:href></d:current-user-principal>`))
+		case r.Method == "PROPFIND" && r.URL.Path == "/cal/principal/":
+			multistatus(w, prop("/cal/principal/", `<c:calendar-home-set><d:href>/cal/home/</d:href></c:calendar-home-set>`))
+		case r.Method == "PROPFIND" && r.URL.Path == "/cal/home/":
+			multistatus(w, prop("/cal/home/", `<d:resourcetype><d:collection/></d:resourcetype>`)+
+				prop("/cal/home/work/", `<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>`))
+		case r.Method == "REPORT" && r.URL.Path == "/cal/home/work/":
+			m := timeRange.FindStringSubmatch(string(body))
+			if m == nil {
+				http.Error(w, "missing time-range", http.StatusBadRequest)
+				return
+			}
+			start, _ := time.Parse("20060102T150405Z", m[1])
+			end, _ := time.Parse("20060102T150405Z", m[2])
+			var responses strings.Builder
+			for _, e := range events {
+				if e.Start.Before(end) && e.Start.Add(e.Duration).After(start) {
+					var data bytes.Buffer
+					xml.EscapeText(&data, []byte(e.ics()))
+					responses.WriteString(prop("/cal/home/work/"+e.UID+".ics", "<c:calendar-data>"+data.String()+"</c:calendar-data>"))
+				}
+			}
+			multistatus(w, responses.String())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestE2E_CalDAVMeetings(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+	day := 24 * time.Hour
+	cal := newMockCalDAVServer([]mockEvent{
+		{UID: "old", Summary: "Old lunch", Start: now.Add(-60 * day), Duration: time.Hour,
+			Attendees: map[string]string{"alice@example.com": "ACCEPTED"}},
+		{UID: "coffee", Summary: "Coffee", Location: "Blue Bottle", Start: now.Add(-10 * day), Duration: 45 * time.Minute,
+			Organizer: "me@example.com", Attendees: map[string]string{"alice@example.com": "ACCEPTED"}},
+		{UID: "plan", Summary: "Planning", Start: now.Add(-3 * day), Duration: time.Hour,
+			Organizer: "me@example.com", Attendees: map[string]string{"alice@example.com": "ACCEPTED", "bob@example.com": "DECLINED"}},
+		{UID: "sync", Summary: "Weekly sync", Start: now.Add(5 * day), Duration: 30 * time.Minute,
+			Attendees: map[string]string{"alice@example.com": "NEEDS-ACTION"}},
+	})
+	t.Cleanup(cal.Close)
+
+	env := setupTest(t)
+	env.addService(t, ServiceConfig{Type: "caldav", Endpoint: cal.URL + "/cal/", Username: "test", Password: "test"})
+	env.backend.seedContactWithEmail("Alice", "1w", "alice@example.com")
+	env.backend.seedContactWithEmail("Bob", "1w", "bob@example.com")
+
+	stdout, stderr, err := env.run(t, "context", "Alice")
+	if err != nil {
+		t.Fatalf("frm context failed: %v\nstderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Meetings:") || !strings.Contains(stdout, "Last met: Planning") || !strings.Contains(stdout, "Next:     Weekly sync") {
+		t.Errorf("unexpected context output: %s\nstderr: %s", stdout, stderr)
+	}
+	stdout, _, _ = env.run(t, "context", "Bob")
+	if strings.Contains(stdout, "Planning") {
+		t.Errorf("Bob declined Planning, should not show it: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "sync-meetings", "--json")
+	if err != nil {
+		t.Fatalf("frm sync-meetings failed: %v", err)
+	}
+	var result struct {
+		Scanned int        `json:"scanned"`
+		Logged  []LogEntry `json:"logged"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, stdout)
+	}
+	if result.Scanned != 2 || len(result.Logged) != 2 {
+		t.Fatalf("expected 2 meetings logged from 2 events, got %+v", result)
+	}
+	coffee := result.Logged[0]
+	if coffee.Contact != "Alice" || coffee.Note != "Coffee" || coffee.Via != "meeting" || coffee.DurationMin != 45 || coffee.Location != "Blue Bottle" {
+		t.Errorf("unexpected entry: %+v", coffee)
+	}
+
+	stdout, _, err = env.run(t, "sync-meetings")
+	if err != nil {
+		t.Fatalf("second frm sync-meetings failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Logged 0 meetings") {
+		t.Errorf("expected nothing new, got: %s", stdout)
+	}
+
+	stdout, _, err = env.run(t, "sync-meetings", "--since", "90d")
+	if err != nil {
+		t.Fatalf("frm sync-meetings --since failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Old lunch") || !strings.Contains(stdout, "Logged 1 meetings from 3 events scanned") {
+		t.Errorf("unexpected --since output: %s", stdout)
+	}
+
+	// Bob never attended, so he is still overdue; Alice is caught up.
+	stdout, _, _ = env.run(t, "check")
+	if strings.Contains(stdout, "Alice") || !strings.Contains(stdout, "Bob") {
+		t.Errorf("unexpected check output: %s", stdout)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frequency is how often to keep in touch with someone. Years and months
+// follow the calendar, so monthly from January 15 is due February 15, and a
+// month from January 31 ends on the last day of February.
+type frequency struct {
+	Years, Months, Weeks, Days int
+}
+
+// frequencyAliases are the named cadences parseFrequency accepts.
+var frequencyAliases = map[string]frequency{
+	"daily":        {Days: 1},
+	"weekly":       {Weeks: 1},
+	"biweekly":     {Weeks: 2},
+	"fortnightly":  {Weeks: 2},
+	"monthly":      {Months: 1},
+	"bimonthly":    {Months: 2},
+	"quarterly":    {Months: 3},
+	"semiannually": {Months: 6},
+	"yearly":       {Years: 1},
+	"annually":     {Years: 1},
+}
+
+// parseFrequency parses a frequency: a named cadence such as quarterly, or
+// one or more counts of d (days), w (weeks), m (months) and y (years), as in
+// 3d, 2w, 1m2w or 1y.
+func parseFrequency(s string) (frequency, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if f, ok := frequencyAliases[s]; ok {
+		return f, nil
+	}
+	if s == "" {
+		return frequency{}, fmt.Errorf("invalid frequency %q: empty", s)
+	}
+	var f frequency
+	seen := make(map[byte]bool)
+	for rest := s; rest != ""; {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(rest) {
+			return frequency{}, fmt.Errorf("invalid frequency %q: use counts of d, w, m or y (e.g. 2w, 1m2w) or a name like quarterly", s)
+		}
+		n, err := strconv.Atoi(rest[:i])
+		if err != nil {
+			return frequency{}, fmt.Errorf("invalid frequency %q: %w", s, err)
+		}
+		unit := rest[i]
+		if seen[unit] {
+			return frequency{}, fmt.Errorf("invalid frequency %q: %c appears twice", s, unit)
+		}
+		seen[unit] = true
+		switch unit {
+		case 'd':
+			f.Days = n
+		case 'w':
+			f.Weeks = n
+		case 'm':
+			f.Months = n
+		case 'y':
+			f.Years = n
+		default:
+			return frequency{}, fmt.Errorf("invalid frequency %q: unknown unit %c (use d, w, m, or y)", s, unit)
+		}
+		rest = rest[i+1:]
+	}
+	if f == (frequency{}) {
+		return frequency{}, fmt.Errorf("invalid frequency %q: must be longer than zero", s)
+	}
+	return f, nil
+}
+
+// frequencyRange is a tracking frequency as stored in X-FRM-FREQUENCY: a
+// single frequency, or a window like 3w-5w. A contact is due soon once From
+// has passed since the last contact, and overdue once By has.
+type frequencyRange struct {
+	From, By frequency
+}
+
+// parseFrequencyRange parses a frequency or a window of two, such as 3w-5w.
+func parseFrequencyRange(s string) (frequencyRange, error) {
+	from, by, ok := strings.Cut(s, "-")
+	if !ok {
+		f, err := parseFrequency(s)
+		return frequencyRange{From: f, By: f}, err
+	}
+	var r frequencyRange
+	var err error
+	if r.From, err = parseFrequency(from); err != nil {
+		return frequencyRange{}, err
+	}
+	if r.By, err = parseFrequency(by); err != nil {
+		return frequencyRange{}, err
+	}
+	if r.By.nominal() <= r.From.nominal() {
+		return frequencyRange{}, fmt.Errorf("invalid frequency %q: the end of the window must be longer than the start", strings.TrimSpace(s))
+	}
+	return r, nil
+}
+
+// isWindow reports whether r is a window rather than a single frequency.
+func (r frequencyRange) isWindow() bool {
+	return r.From != r.By
+}
+
+// String formats r in its compact form, e.g. 2w or 3w-5w.
+func (r frequencyRange) String() string {
+	if !r.isWindow() {
+		return r.From.String()
+	}
+	return r.From.String() + "-" + r.By.String()
+}
+
+// String formats f in its compact form, e.g. 1m2w.
+func (f frequency) String() string {
+	var b strings.Builder
+	for _, part := range []struct {
+		n    int
+		unit string
+	}{{f.Years, "y"}, {f.Months, "m"}, {f.Weeks, "w"}, {f.Days, "d"}} {
+		if part.n > 0 {
+			fmt.Fprintf(&b, "%d%s", part.n, part.unit)
+		}
+	}
+	return b.String()
+}
+
+// after returns the time one frequency after t. A month added to a day the
+// target month doesn't have lands on its last day.
+func (f frequency) after(t time.Time) time.Time {
+	return addMonths(t, f.Years*12+f.Months).AddDate(0, 0, f.Weeks*7+f.Days)
+}
+
+// before returns the time one frequency before t.
+func (f frequency) before(t time.Time) time.Time {
+	return addMonths(t.AddDate(0, 0, -(f.Weeks*7+f.Days)), -(f.Years*12 + f.Months))
+}
+
+// nominal is f's length counting months as 30 days and years as 365, for
+// comparing frequencies and for windows where calendar precision doesn't
+// matter.
+func (f frequency) nominal() time.Duration {
+	days := f.Years*365 + f.Months*30 + f.Weeks*7 + f.Days
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// addMonths adds n calendar months to t, clamping the day to the end of the
+// target month.
+func addMonths(t time.Time, n int) time.Time {
+	if n == 0 {
+		return t
+	}
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); d > last {
+		d = last
+	}
+	return first.AddDate(0, 0, d-1)
+}
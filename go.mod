@@ -4,6 +4,7 @@ go 1.25.0
 
 require (
 	git.sr.ht/~rockorager/go-jmap v0.5.3
+	github.com/emersion/go-imap v1.2.1
 	github.com/emersion/go-vcard v0.0.0-20241024213814-c9703dde27ff
 	github.com/emersion/go-webdav v0.7.0
 	github.com/spf13/cobra v1.10.2
@@ -11,6 +12,8 @@ require (
 )
 
 require (
+	github.com/emersion/go-message v0.18.2 // indirect
+	github.com/emersion/go-sasl v0.0.0-20200509203442-7bfe0ed36a21 // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
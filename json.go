@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
 
 	"github.com/spf13/cobra"
 )
@@ -17,6 +18,17 @@ func isJSONMode(cmd *cobra.Command) bool {
 }
 
 func printJSON(cmd *cobra.Command, v interface{}) error {
+	// Skipped accounts are reported inline: as an "errors" field on object
+	// outputs, and by wrapping list outputs as {"results": [...], "errors": [...]}.
+	if len(accountErrors) > 0 {
+		if m, ok := v.(map[string]interface{}); ok {
+			m["errors"] = accountErrors
+			accountErrorsReported = true
+		} else if v != nil && reflect.TypeOf(v).Kind() == reflect.Slice {
+			v = map[string]interface{}{"results": v, "errors": accountErrors}
+			accountErrorsReported = true
+		}
+	}
 	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling JSON: %w", err)
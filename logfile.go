@@ -2,47 +2,227 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
 type LogEntry struct {
-	Contact string    `json:"contact"`
-	Path    string    `json:"path,omitempty"`
-	Time    time.Time `json:"time"`
-	Note    string    `json:"note,omitempty"`
+	ID          string    `json:"id,omitempty"`
+	Contact     string    `json:"contact"`
+	Path        string    `json:"path,omitempty"`
+	Time        time.Time `json:"time"`
+	Note        string    `json:"note,omitempty"`
+	Via         string    `json:"via,omitempty"`
+	Direction   string    `json:"direction,omitempty"`
+	DurationMin int       `json:"duration_min,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	MessageID   string    `json:"message_id,omitempty"`
+	EventID     string    `json:"event_id,omitempty"`
+}
+
+// knownChannels are suggested values for --via; others are accepted as-is.
+var knownChannels = []string{"call", "text", "email", "in-person", "video", "meeting", "letter", "social"}
+
+// channelAliases maps common spellings onto the known channels.
+var channelAliases = map[string]string{
+	"phone":     "call",
+	"sms":       "text",
+	"message":   "text",
+	"mail":      "email",
+	"inperson":  "in-person",
+	"in person": "in-person",
+	"irl":       "in-person",
+	"meet":      "meeting",
+	"facetime":  "video",
+	"zoom":      "video",
+}
+
+// parseVia normalizes an interaction channel like "Phone" to "call".
+func parseVia(s string) (string, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	if alias, ok := channelAliases[v]; ok {
+		return alias, nil
+	}
+	if v == "" || strings.ContainsAny(v, " \t,") {
+		return "", fmt.Errorf("invalid channel %q (e.g. %s)", s, strings.Join(knownChannels, ", "))
+	}
+	return v, nil
+}
+
+// parseDirection normalizes who reached out: "in" (they did) or "out" (you did).
+func parseDirection(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "in", "inbound", "incoming":
+		return "in", nil
+	case "out", "outbound", "outgoing":
+		return "out", nil
+	}
+	return "", fmt.Errorf("invalid direction %q: use in or out", s)
+}
+
+// details summarizes the structured fields, e.g. "call, outgoing, 45 min, at Blue Bottle".
+func (e LogEntry) details() string {
+	var parts []string
+	if e.Via != "" {
+		parts = append(parts, e.Via)
+	}
+	switch e.Direction {
+	case "in":
+		parts = append(parts, "incoming")
+	case "out":
+		parts = append(parts, "outgoing")
+	}
+	if e.DurationMin > 0 {
+		parts = append(parts, formatMinutes(e.DurationMin))
+	}
+	if e.Location != "" {
+		parts = append(parts, "at "+e.Location)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatMinutes(m int) string {
+	if m < 60 {
+		return fmt.Sprintf("%d min", m)
+	}
+	if m%60 == 0 {
+		return fmt.Sprintf("%dh", m/60)
+	}
+	return fmt.Sprintf("%dh%02dm", m/60, m%60)
 }
 
 func logFilePath() string {
 	return filepath.Join(configDir(), "log.jsonl")
 }
 
-func appendLog(entry LogEntry) error {
-	path := logFilePath()
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+// logLockPath is a separate lock file, since rewrites replace log.jsonl itself.
+func logLockPath() string {
+	return logFilePath() + ".lock"
+}
+
+// withLogLock runs fn while holding an exclusive lock on the log, so
+// concurrent frm processes can't interleave appends or lose a rewrite.
+func withLogLock(fn func() error) error {
+	return lockLog(true, fn)
+}
+
+// withLogReadLock runs fn while holding a shared lock, so reads never see a
+// half-written append.
+func withLogReadLock(fn func() error) error {
+	return lockLog(false, fn)
+}
+
+func lockLog(exclusive bool, fn func() error) error {
+	if err := os.MkdirAll(configDir(), 0o755); err != nil {
 		return fmt.Errorf("creating log directory: %w", err)
 	}
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	f, err := os.OpenFile(logLockPath(), os.O_CREATE|os.O_RDWR, 0o644)
 	if err != nil {
-		return fmt.Errorf("opening log file: %w", err)
+		return fmt.Errorf("opening log lock: %w", err)
 	}
 	defer f.Close()
+	if err := lockFile(f, exclusive); err != nil {
+		return fmt.Errorf("locking log: %w", err)
+	}
+	defer unlockFile(f)
+	return fn()
+}
+
+// newLogID returns a random ID for a new log entry.
+func newLogID() string {
+	var b [6]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// legacyLogID derives a stable ID for entries written before IDs existed,
+// so they can be addressed until a rewrite stores it.
+func legacyLogID(e LogEntry) string {
+	sum := sha256.Sum256([]byte(e.Contact + "\x00" + e.Time.UTC().Format(time.RFC3339Nano) + "\x00" + e.Note))
+	return hex.EncodeToString(sum[:6])
+}
+
+// shortID is the prefix of an entry ID shown in text output.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func appendLog(entry LogEntry) error {
+	if entry.ID == "" {
+		entry.ID = newLogID()
+	}
 	data, err := json.Marshal(entry)
 	if err != nil {
 		return fmt.Errorf("marshaling log entry: %w", err)
 	}
-	if _, err := f.Write(append(data, '\n')); err != nil {
-		return fmt.Errorf("writing log entry: %w", err)
+	return withLogLock(func() error {
+		f, err := os.OpenFile(logFilePath(), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			return fmt.Errorf("opening log file: %w", err)
+		}
+		defer f.Close()
+
+		// If a previous write was cut short, start on a fresh line rather
+		// than gluing this entry onto the torn one.
+		if info, err := f.Stat(); err == nil && info.Size() > 0 {
+			last := make([]byte, 1)
+			if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+				data = append([]byte{'\n'}, data...)
+			}
+		}
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("writing log entry: %w", err)
+		}
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("syncing log file: %w", err)
+		}
+		return nil
+	})
+}
+
+// logLine is one line of log.jsonl. Lines that aren't valid entries keep
+// only their raw bytes and the reason, so rewriting the log never drops them.
+type logLine struct {
+	num   int
+	raw   []byte
+	entry *LogEntry
+	err   error
+}
+
+// parseLogLine decodes and validates one line of the log.
+func parseLogLine(raw []byte) (*LogEntry, error) {
+	var entry LogEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(entry.Contact) == "" {
+		return nil, errors.New("missing contact")
+	}
+	if entry.Time.IsZero() {
+		return nil, errors.New("missing time")
 	}
-	return nil
+	if entry.ID == "" {
+		entry.ID = legacyLogID(entry)
+	}
+	return &entry, nil
 }
 
-func readLog() ([]LogEntry, error) {
-	path := logFilePath()
-	f, err := os.Open(path)
+// readLogLines reads every line of the log. Callers must hold the log lock.
+func readLogLines() ([]logLine, error) {
+	f, err := os.Open(logFilePath())
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
@@ -51,16 +231,178 @@ func readLog() ([]LogEntry, error) {
 	}
 	defer f.Close()
 
+	var lines []logLine
+	r := bufio.NewReader(f)
+	for num := 1; ; num++ {
+		raw, err := r.ReadBytes('\n')
+		if len(raw) > 0 {
+			raw = bytes.TrimRight(raw, "\r\n")
+			if len(bytes.TrimSpace(raw)) > 0 {
+				line := logLine{num: num, raw: raw}
+				line.entry, line.err = parseLogLine(raw)
+				lines = append(lines, line)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading log file: %w", err)
+		}
+	}
+	return lines, nil
+}
+
+// writeLogLines atomically replaces the log. Valid entries are re-encoded so
+// derived IDs are stored; other lines are kept verbatim.
+func writeLogLines(lines []logLine) error {
+	var buf bytes.Buffer
+	for _, l := range lines {
+		if l.entry != nil {
+			data, err := json.Marshal(l.entry)
+			if err != nil {
+				return fmt.Errorf("marshaling log entry: %w", err)
+			}
+			buf.Write(data)
+		} else {
+			buf.Write(l.raw)
+		}
+		buf.WriteByte('\n')
+	}
+	return writeFileAtomic(logFilePath(), buf.Bytes())
+}
+
+func readLog() ([]LogEntry, error) {
+	if _, err := os.Stat(logFilePath()); os.IsNotExist(err) {
+		return nil, nil
+	}
+	var lines []logLine
+	err := withLogReadLock(func() error {
+		var err error
+		lines, err = readLogLines()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 	var entries []LogEntry
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		var entry LogEntry
-		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
-			continue // skip malformed lines
+	bad := 0
+	for _, l := range lines {
+		if l.entry == nil {
+			bad++
+			continue
 		}
-		entries = append(entries, entry)
+		entries = append(entries, *l.entry)
+	}
+	if bad > 0 {
+		fmt.Fprintf(os.Stderr, "warning: skipped %d malformed line(s) in %s; run 'frm log verify' for details\n", bad, logFilePath())
+	}
+	return entries, nil
+}
+
+// errLogEntryNotFound is returned when no log entry matches an ID.
+var errLogEntryNotFound = errors.New("no log entry with that ID")
+
+// findLogEntry returns the index of the entry whose ID starts with prefix.
+func findLogEntry(lines []logLine, prefix string) (int, error) {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if prefix == "" {
+		return -1, errLogEntryNotFound
 	}
-	return entries, scanner.Err()
+	found := -1
+	for i, l := range lines {
+		if l.entry == nil || !strings.HasPrefix(l.entry.ID, prefix) {
+			continue
+		}
+		if l.entry.ID == prefix {
+			return i, nil
+		}
+		if found >= 0 && lines[found].entry.ID != l.entry.ID {
+			return -1, fmt.Errorf("log entry ID %q is ambiguous; use more characters", prefix)
+		}
+		if found < 0 {
+			found = i
+		}
+	}
+	if found < 0 {
+		return -1, fmt.Errorf("%w: %s", errLogEntryNotFound, prefix)
+	}
+	return found, nil
+}
+
+// updateLogEntry finds the entry with the given ID (or unique prefix) and
+// rewrites the log with change applied. change returns nil to delete the
+// entry. The old entry is returned.
+func updateLogEntry(id string, dryRun bool, change func(LogEntry) (*LogEntry, error)) (LogEntry, error) {
+	var old LogEntry
+	err := withLogLock(func() error {
+		lines, err := readLogLines()
+		if err != nil {
+			return err
+		}
+		i, err := findLogEntry(lines, id)
+		if err != nil {
+			return err
+		}
+		old = *lines[i].entry
+		updated, err := change(old)
+		if err != nil || dryRun {
+			return err
+		}
+		if updated == nil {
+			lines = append(lines[:i], lines[i+1:]...)
+		} else {
+			lines[i].entry = updated
+		}
+		return writeLogLines(lines)
+	})
+	return old, err
+}
+
+// logQuarantinePath holds lines removed from the log by 'frm log repair'.
+func logQuarantinePath() string {
+	return logFilePath() + ".quarantine"
+}
+
+// quarantineLogLines moves malformed lines out of the log into the
+// quarantine file, returning them.
+func quarantineLogLines(dryRun bool) ([]logLine, error) {
+	var bad []logLine
+	err := withLogLock(func() error {
+		lines, err := readLogLines()
+		if err != nil {
+			return err
+		}
+		var good []logLine
+		for _, l := range lines {
+			if l.entry == nil {
+				bad = append(bad, l)
+			} else {
+				good = append(good, l)
+			}
+		}
+		if len(bad) == 0 || dryRun {
+			return nil
+		}
+
+		// Save the bad lines before removing them, so nothing is lost if
+		// the rewrite fails.
+		f, err := os.OpenFile(logQuarantinePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("opening quarantine file: %w", err)
+		}
+		defer f.Close()
+		for _, l := range bad {
+			if _, err := f.Write(append(l.raw, '\n')); err != nil {
+				return fmt.Errorf("writing quarantine file: %w", err)
+			}
+		}
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("syncing quarantine file: %w", err)
+		}
+		return writeLogLines(good)
+	})
+	return bad, err
 }
 
 // lastContactTime returns the most recent log time keyed by both contact name and path.
@@ -63,9 +63,12 @@ func main() {
 	// Silence cobra's default error printing so we can handle it ourselves.
 	rootCmd.SilenceErrors = true
 
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	jsonFlag, _ := rootCmd.PersistentFlags().GetBool("json")
+	reportAccountErrors(jsonFlag)
+	if err != nil {
 		// If --json was set on the command that failed, output structured JSON error.
-		if jsonFlag, _ := rootCmd.PersistentFlags().GetBool("json"); jsonFlag {
+		if jsonFlag {
 			printJSONError(rootCmd, err)
 		} else {
 			fmt.Fprintln(os.Stderr, err)
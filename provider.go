@@ -1,46 +1,419 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/emersion/go-vcard"
+	"github.com/emersion/go-webdav/carddav"
 )
 
-// ContextProvider returns lines of context about a contact.
+// ContextProvider returns context about a contact. Implementations must be
+// safe for concurrent use.
 type ContextProvider interface {
-	Name() string
-	GetContext(card vcard.Card) ([]string, error)
+	GetContext(obj carddav.AddressObject) ([]ContextItem, error)
 }
 
-// initProviders creates providers based on config.
-func initProviders(cfg Config) []ContextProvider {
-	var providers []ContextProvider
-	for _, svc := range cfg.jmapServices() {
-		p, err := newJMAPProvider(svc)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "jmap provider: %v\n", err)
+// ContextItem is one piece of context about a contact, such as an email or a
+// meeting.
+type ContextItem struct {
+	Provider string    `json:"provider"`
+	Kind     string    `json:"kind"` // "email", "meeting", or whatever an exec provider reports
+	Title    string    `json:"title"`
+	Time     time.Time `json:"time,omitzero"`
+	AllDay   bool      `json:"all_day,omitempty"` // Time is a date, not a moment
+	URL      string    `json:"url,omitempty"`
+	ID       string    `json:"id,omitempty"` // e.g. a Message-ID or event UID
+	Snippet  string    `json:"snippet,omitempty"`
+
+	// Email threads (JMAP)
+	ThreadID      string `json:"thread_id,omitempty"`
+	From          string `json:"from,omitempty"`           // sender of the latest message
+	AwaitingReply bool   `json:"awaiting_reply,omitempty"` // the contact wrote last and you haven't answered
+}
+
+// snippetWidth is how much of a snippet the text output shows.
+const snippetWidth = 100
+
+// lines formats an item for text output.
+func (it ContextItem) lines() []string {
+	line := "  "
+	if it.Kind == "meeting" {
+		if it.Time.Before(time.Now()) {
+			line += "Last met: "
 		} else {
-			providers = append(providers, p)
+			line += "Next:     "
+		}
+	}
+	line += it.Title
+	var details []string
+	switch {
+	case it.Time.IsZero():
+	case it.AllDay || it.Kind != "meeting":
+		details = append(details, it.Time.Format("2006-01-02"))
+	default:
+		details = append(details, it.Time.Local().Format("2006-01-02 15:04"))
+	}
+	if it.From != "" {
+		details = append(details, "from "+it.From)
+	}
+	if len(details) > 0 {
+		line += " (" + strings.Join(details, ", ") + ")"
+	}
+	if it.AwaitingReply {
+		line += " [awaiting your reply]"
+	}
+	lines := []string{line}
+	if it.Snippet != "" {
+		snippet := strings.Join(strings.Fields(it.Snippet), " ")
+		if r := []rune(snippet); len(r) > snippetWidth {
+			snippet = string(r[:snippetWidth]) + "..."
 		}
+		lines = append(lines, "    "+snippet)
+	}
+	if it.URL != "" {
+		lines = append(lines, "    "+it.URL)
+	}
+	return lines
+}
+
+// providerContext is one provider's context about a contact, or the error
+// that kept it from getting any.
+type providerContext struct {
+	Provider string        `json:"provider"`
+	Items    []ContextItem `json:"items"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// contextConcurrency caps how many provider lookups run at once.
+const contextConcurrency = 8
+
+// defaultContextTTL is how long results from network providers (JMAP, IMAP,
+// CalDAV) are reused. Local providers aren't cached unless cache_ttl is set.
+const defaultContextTTL = time.Hour
+
+// batchContextProvider is implemented by providers that can look up several
+// contacts in one round trip.
+type batchContextProvider interface {
+	ContextProvider
+	batchSize() int
+	getContextBatch(objs []carddav.AddressObject) ([][]ContextItem, []error)
+}
+
+// closingContextProvider is implemented by providers that hold a connection
+// open between lookups.
+type closingContextProvider interface {
+	ContextProvider
+	Close() error
+}
+
+// contextSource is a configured provider. It connects on first use, so
+// commands whose results are all cached never touch the network.
+type contextSource struct {
+	svc  ServiceConfig
+	name string
+	ttl  time.Duration
+	key  string // identifies the service in the context cache
+
+	once sync.Once
+	p    ContextProvider
+	err  error
+}
+
+func (s *contextSource) provider() (ContextProvider, error) {
+	s.once.Do(func() {
+		s.p, s.err = newContextProvider(s.svc)
+		if s.err != nil {
+			s.err = fmt.Errorf("%s: %w", s.svc.label(), s.err)
+		}
+	})
+	return s.p, s.err
+}
+
+func newContextProvider(svc ServiceConfig) (ContextProvider, error) {
+	switch svc.Type {
+	case "jmap":
+		return newJMAPProvider(svc)
+	case "imap":
+		return newIMAPProvider(svc)
+	case "maildir", "mbox":
+		return newLocalMailProvider(svc)
+	case "caldav":
+		return newCalDAVProvider(svc)
+	case "exec":
+		return newExecProvider(svc)
+	}
+	return nil, fmt.Errorf("%s is not a context provider", svc.Type)
+}
+
+// contextName is the heading a service's context appears under.
+func contextName(svc ServiceConfig) string {
+	switch svc.Type {
+	case "caldav":
+		return "Meetings"
+	case "exec":
+		if svc.Name != "" {
+			return svc.Name
+		}
+		return filepath.Base(svc.Command[0])
+	}
+	return "Recent emails"
+}
+
+// contextProviders is every configured provider plus the cache of their
+// results.
+type contextProviders struct {
+	sources []*contextSource
+	cache   *contextCache
+	refresh bool // skip cached results, but still cache fresh ones
+}
+
+// initProviders sets up a provider for each context service in config, in
+// config order. Nothing is contacted until a lookup needs it.
+func initProviders(cfg Config) *contextProviders {
+	providers := &contextProviders{cache: loadContextCache()}
+	for _, svc := range cfg.Services {
+		switch svc.Type {
+		case "jmap", "imap", "maildir", "mbox", "caldav", "exec":
+		default:
+			continue
+		}
+		ttl := time.Duration(0)
+		switch svc.Type {
+		case "jmap", "imap", "caldav":
+			ttl = defaultContextTTL
+		}
+		if svc.CacheTTL != "" {
+			// Validated by loadConfig.
+			ttl, _ = time.ParseDuration(svc.CacheTTL)
+		}
+		id, _ := json.Marshal(svc)
+		sum := sha256.Sum256(id)
+		providers.sources = append(providers.sources, &contextSource{
+			svc:  svc,
+			name: contextName(svc),
+			ttl:  ttl,
+			key:  hex.EncodeToString(sum[:8]),
+		})
 	}
 	return providers
 }
 
-// collectContext gathers context from all providers for a given card.
-func collectContext(providers []ContextProvider, card vcard.Card) []string {
-	var all []string
-	for _, p := range providers {
-		lines, err := p.GetContext(card)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s: %v\n", p.Name(), err)
+// close closes the connections of any providers that were used. Call it
+// once lookups are done.
+func (providers *contextProviders) close() {
+	for _, src := range providers.sources {
+		if src.err != nil {
 			continue
 		}
-		if len(lines) == 0 {
+		if c, ok := src.p.(closingContextProvider); ok {
+			if err := c.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: closing %s: %v\n", src.svc.label(), err)
+			}
+		}
+	}
+}
+
+// collectContext gathers context from all providers for a given contact.
+func collectContext(providers *contextProviders, obj carddav.AddressObject) []providerContext {
+	return collectContextAll(providers, []carddav.AddressObject{obj})[0]
+}
+
+// collectContextAll gathers context for many contacts at once. Cached results
+// are reused; the rest are looked up concurrently, in batches where the
+// provider supports it, and cached. Providers with nothing to say about a
+// contact are left out of its context.
+func collectContextAll(providers *contextProviders, objs []carddav.AddressObject) [][]providerContext {
+	type result struct {
+		items []ContextItem
+		err   error
+		fresh bool
+	}
+	now := time.Now()
+	results := make([][]result, len(providers.sources))
+	sem := make(chan struct{}, contextConcurrency)
+	var wg sync.WaitGroup
+	run := func(f func()) {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			f()
+		})
+	}
+	for i, src := range providers.sources {
+		res := make([]result, len(objs))
+		results[i] = res
+		var misses []int
+		for j, obj := range objs {
+			if items, ok := providers.cache.get(src, obj, now); ok && !providers.refresh {
+				res[j].items = items
+			} else {
+				misses = append(misses, j)
+			}
+		}
+		if len(misses) == 0 {
 			continue
 		}
-		all = append(all, p.Name()+":")
-		all = append(all, lines...)
+
+		wg.Go(func() {
+			p, err := src.provider()
+			if err != nil {
+				for _, j := range misses {
+					res[j].err = err
+				}
+				return
+			}
+			if bp, ok := p.(batchContextProvider); ok {
+				size := bp.batchSize()
+				for start := 0; start < len(misses); start += size {
+					chunk := misses[start:min(start+size, len(misses))]
+					run(func() {
+						batch := make([]carddav.AddressObject, len(chunk))
+						for k, j := range chunk {
+							batch[k] = objs[j]
+						}
+						items, errs := bp.getContextBatch(batch)
+						for k, j := range chunk {
+							res[j] = result{items: items[k], err: errs[k], fresh: true}
+						}
+					})
+				}
+				return
+			}
+			for _, j := range misses {
+				run(func() {
+					items, err := p.GetContext(objs[j])
+					res[j] = result{items: items, err: err, fresh: true}
+				})
+			}
+		})
+	}
+	wg.Wait()
+
+	all := make([][]providerContext, len(objs))
+	for i, src := range providers.sources {
+		for j, r := range results[i] {
+			if r.err != nil {
+				all[j] = append(all[j], providerContext{Provider: src.name, Items: []ContextItem{}, Error: r.err.Error()})
+				continue
+			}
+			if r.fresh {
+				providers.cache.put(src, objs[j], r.items, now)
+			}
+			if len(r.items) == 0 {
+				continue
+			}
+			for k := range r.items {
+				r.items[k].Provider = src.name
+			}
+			all[j] = append(all[j], providerContext{Provider: src.name, Items: r.items})
+		}
+	}
+	if err := providers.cache.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: saving context cache: %v\n", err)
 	}
 	return all
 }
+
+// contextCache keeps provider results on disk, keyed by service and by
+// contact path and ETag, so a changed card is looked up again.
+type contextCache struct {
+	path  string
+	dirty bool
+
+	Entries map[string]cachedContext `json:"entries"`
+}
+
+type cachedContext struct {
+	Expires time.Time     `json:"expires"`
+	Items   []ContextItem `json:"items"`
+}
+
+func contextCachePath() string {
+	return filepath.Join(cacheDir(), "context.json")
+}
+
+// loadContextCache reads the context cache. A missing or corrupt cache
+// yields an empty one.
+func loadContextCache() *contextCache {
+	c := &contextCache{path: contextCachePath()}
+	if data, err := os.ReadFile(c.path); err == nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: ignoring corrupt context cache %s: %v\n", c.path, err)
+		}
+	}
+	if c.Entries == nil {
+		c.Entries = make(map[string]cachedContext)
+	}
+	return c
+}
+
+func contextCacheKey(src *contextSource, obj carddav.AddressObject) string {
+	return src.key + "|" + obj.Path + "|" + obj.ETag
+}
+
+func (c *contextCache) get(src *contextSource, obj carddav.AddressObject, now time.Time) ([]ContextItem, bool) {
+	if src.ttl <= 0 {
+		return nil, false
+	}
+	e, ok := c.Entries[contextCacheKey(src, obj)]
+	if !ok || !now.Before(e.Expires) {
+		return nil, false
+	}
+	return e.Items, true
+}
+
+func (c *contextCache) put(src *contextSource, obj carddav.AddressObject, items []ContextItem, now time.Time) {
+	if src.ttl <= 0 {
+		return
+	}
+	c.Entries[contextCacheKey(src, obj)] = cachedContext{Expires: now.Add(src.ttl), Items: items}
+	c.dirty = true
+}
+
+// save writes the cache if it changed, dropping expired entries.
+func (c *contextCache) save() error {
+	if !c.dirty {
+		return nil
+	}
+	now := time.Now()
+	for k, e := range c.Entries {
+		if !now.Before(e.Expires) {
+			delete(c.Entries, k)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshaling context cache: %w", err)
+	}
+	if err := writeFileAtomic(c.path, data); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// contextLines formats collected context for text output, one heading per
+// provider.
+func contextLines(groups []providerContext) []string {
+	var lines []string
+	for _, g := range groups {
+		lines = append(lines, g.Provider+":")
+		if g.Error != "" {
+			lines = append(lines, "  error: "+g.Error)
+		}
+		for _, it := range g.Items {
+			lines = append(lines, it.lines()...)
+		}
+	}
+	return lines
+}
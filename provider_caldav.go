@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-webdav/carddav"
+)
+
+// calendarWindow is how far back and ahead context looks for meetings.
+const calendarWindow = 365 * 24 * time.Hour
+
+// calEvent is one occurrence of a calendar event.
+type calEvent struct {
+	UID       string
+	Summary   string
+	Location  string
+	Start     time.Time
+	End       time.Time
+	AllDay    bool
+	Organizer string
+	// Attendees maps each attendee's lowercased address to its PARTSTAT.
+	Attendees map[string]string
+}
+
+// involves reports whether any of addrs organized or (without declining)
+// attended the event.
+func (e calEvent) involves(addrs map[string]bool) bool {
+	if addrs[e.Organizer] {
+		return true
+	}
+	for addr, partstat := range e.Attendees {
+		if addrs[addr] && partstat != "DECLINED" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseICalEvents extracts the VEVENTs from an iCalendar document. Only the
+// properties frm uses are read; recurring events are expected to have been
+// expanded by the server.
+func parseICalEvents(data string) ([]calEvent, error) {
+	var events []calEvent
+	var cur *calEvent
+	for _, line := range unfoldICal(data) {
+		name, params, value := splitICalLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			cur = &calEvent{Attendees: map[string]string{}}
+		case name == "END" && value == "VEVENT" && cur != nil:
+			if !cur.Start.IsZero() {
+				if cur.End.IsZero() {
+					cur.End = cur.Start
+				}
+				events = append(events, *cur)
+			}
+			cur = nil
+		case cur == nil:
+		case name == "UID":
+			cur.UID = value
+		case name == "SUMMARY":
+			cur.Summary = unescapeICal(value)
+		case name == "LOCATION":
+			cur.Location = unescapeICal(value)
+		case name == "DTSTART", name == "DTEND":
+			t, allDay, err := parseICalTime(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if name == "DTSTART" {
+				cur.Start, cur.AllDay = t, allDay
+			} else {
+				cur.End = t
+			}
+		case name == "ORGANIZER":
+			cur.Organizer = mailtoAddr(value)
+		case name == "ATTENDEE":
+			if addr := mailtoAddr(value); addr != "" {
+				cur.Attendees[addr] = strings.ToUpper(params["PARTSTAT"])
+			}
+		}
+	}
+	return events, nil
+}
+
+// unfoldICal splits an iCalendar document into logical lines, joining
+// continuation lines (which start with a space or tab).
+func unfoldICal(data string) []string {
+	var lines []string
+	sc := bufio.NewScanner(strings.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitICalLine splits "NAME;PARAM=x:value" into its parts. Parameter names
+// are upper-cased; quoted parameter values are unquoted.
+func splitICalLine(line string) (string, map[string]string, string) {
+	params := map[string]string{}
+	// The value starts at the first colon outside a quoted parameter value.
+	colon := -1
+	inQuote := false
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		} else if r == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return strings.ToUpper(line), params, ""
+	}
+	parts := strings.Split(line[:colon], ";")
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:]
+}
+
+func unescapeICal(s string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// mailtoAddr returns the lowercased address of a "mailto:" URI.
+func mailtoAddr(v string) string {
+	if len(v) < 7 || !strings.EqualFold(v[:7], "mailto:") {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(v[7:]))
+}
+
+// parseICalTime parses a DATE or DATE-TIME value, honouring TZID.
+func parseICalTime(value string, params map[string]string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// calDAVClient speaks just enough CalDAV to find calendars and query events.
+type calDAVClient struct {
+	http     *http.Client
+	base     *url.URL
+	username string
+	password string
+}
+
+func newCalDAVClient(svc ServiceConfig) (*calDAVClient, error) {
+	base, err := url.Parse(svc.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing endpoint: %w", err)
+	}
+	return &calDAVClient{
+		http:     &http.Client{Timeout: 30 * time.Second},
+		base:     base,
+		username: svc.Username,
+		password: svc.Password,
+	}, nil
+}
+
+type davHref struct {
+	Href string `xml:"DAV: href"`
+}
+
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Prop struct {
+				CurrentUserPrincipal davHref `xml:"DAV: current-user-principal"`
+				CalendarHomeSet      davHref `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+				ResourceType         struct {
+					Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+				} `xml:"DAV: resourcetype"`
+				CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// do sends a PROPFIND or REPORT and decodes the multistatus reply.
+func (c *calDAVClient) do(method, href, depth, body string) (*davMultistatus, error) {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return nil, err
+	}
+	u := c.base.ResolveReference(ref)
+	req, err := http.NewRequest(method, u.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Content-Type", `application/xml; charset="utf-8"`)
+	req.Header.Set("Depth", depth)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("%s %s: %s", method, u.Path, resp.Status)
+	}
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("%s %s: decoding response: %w", method, u.Path, err)
+	}
+	return &ms, nil
+}
+
+// propfindHref returns the first href of a property, or "" if unset.
+func (c *calDAVClient) propfindHref(href, prop string, pick func(*davMultistatus) string) (string, error) {
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop>` + prop + `</d:prop></d:propfind>`
+	ms, err := c.do("PROPFIND", href, "0", body)
+	if err != nil {
+		return "", err
+	}
+	return pick(ms), nil
+}
+
+// calendars discovers the calendar collections: endpoint, then
+// current-user-principal, then calendar-home-set. If the endpoint has no
+// principal it is taken to be the calendar home itself.
+func (c *calDAVClient) calendars() ([]string, error) {
+	home := c.base.Path
+	principal, err := c.propfindHref(home, "<d:current-user-principal/>", func(ms *davMultistatus) string {
+		for _, r := range ms.Responses {
+			for _, ps := range r.Propstat {
+				if h := ps.Prop.CurrentUserPrincipal.Href; h != "" {
+					return h
+				}
+			}
+		}
+		return ""
+	})
+	if err != nil {
+		return nil, fmt.Errorf("finding principal: %w", err)
+	}
+	if principal != "" {
+		h, err := c.propfindHref(principal, "<c:calendar-home-set/>", func(ms *davMultistatus) string {
+			for _, r := range ms.Responses {
+				for _, ps := range r.Propstat {
+					if h := ps.Prop.CalendarHomeSet.Href; h != "" {
+						return h
+					}
+				}
+			}
+			return ""
+		})
+		if err != nil {
+			return nil, fmt.Errorf("finding calendar home: %w", err)
+		}
+		if h != "" {
+			home = h
+		}
+	}
+
+	ms, err := c.do("PROPFIND", home, "1", `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/></d:prop></d:propfind>`)
+	if err != nil {
+		return nil, fmt.Errorf("listing calendars: %w", err)
+	}
+	var cals []string
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstat {
+			if ps.Prop.ResourceType.Calendar != nil {
+				cals = append(cals, r.Href)
+				break
+			}
+		}
+	}
+	return cals, nil
+}
+
+// events returns event occurrences overlapping [from, to) across all
+// calendars, with recurrences expanded by the server, sorted by start.
+func (c *calDAVClient) events(from, to time.Time) ([]calEvent, error) {
+	cals, err := c.calendars()
+	if err != nil {
+		return nil, err
+	}
+	start, end := from.UTC().Format("20060102T150405Z"), to.UTC().Format("20060102T150405Z")
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><c:calendar-data><c:expand start="` + start + `" end="` + end + `"/></c:calendar-data></d:prop>
+  <c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT">
+    <c:time-range start="` + start + `" end="` + end + `"/>
+  </c:comp-filter></c:comp-filter></c:filter>
+</c:calendar-query>`
+
+	var events []calEvent
+	for _, cal := range cals {
+		ms, err := c.do("REPORT", cal, "1", body)
+		if err != nil {
+			return nil, fmt.Errorf("querying %s: %w", cal, err)
+		}
+		for _, r := range ms.Responses {
+			for _, ps := range r.Propstat {
+				if ps.Prop.CalendarData == "" {
+					continue
+				}
+				evs, err := parseICalEvents(ps.Prop.CalendarData)
+				if err != nil {
+					return nil, fmt.Errorf("parsing %s: %w", r.Href, err)
+				}
+				events = append(events, evs...)
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events, nil
+}
+
+type calDAVProvider struct {
+	client *calDAVClient
+	// events is loaded on first use and shared by every lookup, so triage
+	// queries the server once rather than per contact.
+	mu     sync.Mutex
+	events []calEvent
+	loaded bool
+}
+
+func newCalDAVProvider(svc ServiceConfig) (*calDAVProvider, error) {
+	client, err := newCalDAVClient(svc)
+	if err != nil {
+		return nil, err
+	}
+	return &calDAVProvider{client: client}, nil
+}
+
+// loadEvents fetches events around now on first use.
+func (p *calDAVProvider) loadEvents() ([]calEvent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.loaded {
+		now := time.Now()
+		events, err := p.client.events(now.Add(-calendarWindow), now.Add(calendarWindow))
+		if err != nil {
+			return nil, err
+		}
+		p.events, p.loaded = events, true
+	}
+	return p.events, nil
+}
+
+func (p *calDAVProvider) GetContext(obj carddav.AddressObject) ([]ContextItem, error) {
+	addrs := make(map[string]bool)
+	for _, a := range cardEmails(obj.Card) {
+		addrs[a] = true
+	}
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	events, err := p.loadEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var last, next *calEvent
+	for i, e := range events {
+		if !e.involves(addrs) {
+			continue
+		}
+		if e.Start.Before(now) {
+			last = &events[i]
+		} else if next == nil {
+			next = &events[i]
+		}
+	}
+	var items []ContextItem
+	for _, e := range []*calEvent{last, next} {
+		if e != nil {
+			items = append(items, ContextItem{Kind: "meeting", Title: e.Summary, Time: e.Start, AllDay: e.AllDay, ID: eventID(*e), Snippet: e.Location})
+		}
+	}
+	return items, nil
+}
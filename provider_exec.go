@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-vcard"
+	"github.com/emersion/go-webdav/carddav"
+)
+
+// defaultExecTimeout bounds how long an exec provider may run per contact.
+const defaultExecTimeout = 10 * time.Second
+
+// execContact is the JSON an exec provider's command receives on stdin.
+type execContact struct {
+	Name   string            `json:"name"`
+	Emails []string          `json:"emails"`
+	Phones []string          `json:"phones"`
+	Org    string            `json:"org,omitempty"`
+	Path   string            `json:"path"`
+	Fields map[string]string `json:"fields"` // X-FRM-* properties, e.g. X-FRM-GROUP
+}
+
+// execItem is one line of an exec provider's output. A line may also be a
+// bare JSON string, taken as the title.
+type execItem struct {
+	Kind    string `json:"kind,omitempty"` // defaults to "note"
+	Title   string `json:"title"`
+	Time    string `json:"time,omitempty"` // RFC 3339 or YYYY-MM-DD
+	URL     string `json:"url,omitempty"`
+	ID      string `json:"id,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// execProvider gets context by running an external command once per contact.
+type execProvider struct {
+	command []string
+	timeout time.Duration
+}
+
+func newExecProvider(svc ServiceConfig) (*execProvider, error) {
+	timeout := defaultExecTimeout
+	if svc.Timeout != "" {
+		d, err := time.ParseDuration(svc.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q: use a duration like 10s", svc.Timeout)
+		}
+		timeout = d
+	}
+	command := append([]string{expandHome(svc.Command[0])}, svc.Command[1:]...)
+	return &execProvider{command: command, timeout: timeout}, nil
+}
+
+// newExecContact builds the stdin payload for a contact.
+func newExecContact(obj carddav.AddressObject) execContact {
+	c := execContact{
+		Name:   contactName(obj),
+		Emails: cardEmails(obj.Card),
+		Phones: obj.Card.Values(vcard.FieldTelephone),
+		Org:    obj.Card.PreferredValue(vcard.FieldOrganization),
+		Path:   obj.Path,
+		Fields: make(map[string]string),
+	}
+	for key := range obj.Card {
+		if strings.HasPrefix(key, "X-FRM-") {
+			c.Fields[key] = obj.Card.PreferredValue(key)
+		}
+	}
+	if c.Emails == nil {
+		c.Emails = []string{}
+	}
+	if c.Phones == nil {
+		c.Phones = []string{}
+	}
+	return c
+}
+
+func (p *execProvider) GetContext(obj carddav.AddressObject) ([]ContextItem, error) {
+	input, err := json.Marshal(newExecContact(obj))
+	if err != nil {
+		return nil, fmt.Errorf("marshaling contact: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.command[0], p.command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	// Don't wait on grandchildren that still hold stdout after a timeout.
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timed out after %s", p.timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	return parseExecOutput(stdout.Bytes())
+}
+
+// parseExecOutput reads one JSON item per line, skipping blank lines. Items
+// keep the command's order.
+func parseExecOutput(out []byte) ([]ContextItem, error) {
+	var items []ContextItem
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var item execItem
+		if line[0] == '"' {
+			if err := json.Unmarshal(line, &item.Title); err != nil {
+				return nil, fmt.Errorf("output line %d: %w", n, err)
+			}
+		} else if err := json.Unmarshal(line, &item); err != nil {
+			return nil, fmt.Errorf("output line %d: %w", n, err)
+		}
+		if item.Title == "" {
+			return nil, fmt.Errorf("output line %d: missing title", n)
+		}
+		ci := ContextItem{Kind: item.Kind, Title: item.Title, URL: item.URL, ID: item.ID, Snippet: item.Snippet}
+		if ci.Kind == "" {
+			ci.Kind = "note"
+		}
+		if item.Time != "" {
+			t, err := time.Parse(time.RFC3339, item.Time)
+			if err != nil {
+				if t, err = time.Parse("2006-01-02", item.Time); err != nil {
+					return nil, fmt.Errorf("output line %d: invalid time %q: use RFC 3339 or YYYY-MM-DD", n, item.Time)
+				}
+				ci.AllDay = true
+			}
+			ci.Time = t
+		}
+		items = append(items, ci)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading output: %w", err)
+	}
+	return items, nil
+}
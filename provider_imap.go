@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-webdav/carddav"
+)
+
+// imapTimeout bounds connecting and each command, so an unresponsive server
+// can't hang context or triage.
+const imapTimeout = 30 * time.Second
+
+type imapProvider struct {
+	// mu serializes lookups, which select a mailbox before searching it.
+	mu         sync.Mutex
+	client     *client.Client
+	mailboxes  []string
+	maxResults int
+}
+
+// imapAddr returns host:port for an IMAP service, defaulting the port to 993
+// for TLS and 143 otherwise.
+func imapAddr(svc ServiceConfig) string {
+	port := svc.Port
+	if port == 0 {
+		port = 993
+		if svc.TLS == "starttls" || svc.TLS == "none" {
+			port = 143
+		}
+	}
+	return net.JoinHostPort(svc.Host, strconv.Itoa(port))
+}
+
+func newIMAPProvider(svc ServiceConfig) (*imapProvider, error) {
+	addr := imapAddr(svc)
+	dialer := &net.Dialer{Timeout: imapTimeout}
+	tlsConfig := &tls.Config{ServerName: svc.Host}
+
+	var c *client.Client
+	var err error
+	switch svc.TLS {
+	case "", "tls":
+		c, err = client.DialWithDialerTLS(dialer, addr, tlsConfig)
+	case "starttls", "none":
+		c, err = client.DialWithDialer(dialer, addr)
+		if err == nil && svc.TLS == "starttls" {
+			err = c.StartTLS(tlsConfig)
+		}
+	default:
+		return nil, fmt.Errorf("unknown tls mode %q (use tls, starttls, or none)", svc.TLS)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	c.Timeout = imapTimeout
+
+	if err := c.Login(svc.Username, svc.Password); err != nil {
+		c.Logout()
+		return nil, fmt.Errorf("logging in: %w", err)
+	}
+
+	mailboxes := svc.Mailboxes
+	if len(mailboxes) == 0 {
+		mailboxes, err = defaultIMAPMailboxes(c)
+		if err != nil {
+			c.Logout()
+			return nil, err
+		}
+	}
+
+	maxResults := svc.MaxResults
+	if maxResults <= 0 {
+		maxResults = 3
+	}
+
+	return &imapProvider{
+		client:     c,
+		mailboxes:  mailboxes,
+		maxResults: maxResults,
+	}, nil
+}
+
+// Close logs out and closes the connection.
+func (p *imapProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.client.Logout()
+}
+
+// defaultIMAPMailboxes is INBOX plus the server's \Sent mailbox, if it
+// advertises one, so both directions of a conversation are found.
+func defaultIMAPMailboxes(c *client.Client) ([]string, error) {
+	mailboxes := []string{"INBOX"}
+	ch := make(chan *imap.MailboxInfo, 10)
+	done := make(chan error, 1)
+	go func() { done <- c.List("", "*", ch) }()
+	for info := range ch {
+		for _, attr := range info.Attributes {
+			if attr == imap.SentAttr {
+				mailboxes = append(mailboxes, info.Name)
+			}
+		}
+	}
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("listing mailboxes: %w", err)
+	}
+	return mailboxes, nil
+}
+
+func (p *imapProvider) GetContext(obj carddav.AddressObject) ([]ContextItem, error) {
+	addrs := extractEmails(obj.Card)
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	criteria := buildIMAPCriteria(addrs)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var msgs []ContextItem
+	for _, mbox := range p.mailboxes {
+		if _, err := p.client.Select(mbox, true); err != nil {
+			return nil, fmt.Errorf("selecting %s: %w", mbox, err)
+		}
+		uids, err := p.client.UidSearch(criteria)
+		if err != nil {
+			return nil, fmt.Errorf("searching %s: %w", mbox, err)
+		}
+		if len(uids) == 0 {
+			continue
+		}
+		// UIDs grow as mail arrives, so the highest are the most recent.
+		sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+		if len(uids) > p.maxResults {
+			uids = uids[len(uids)-p.maxResults:]
+		}
+		seqset := new(imap.SeqSet)
+		seqset.AddNum(uids...)
+
+		ch := make(chan *imap.Message, len(uids))
+		if err := p.client.UidFetch(seqset, []imap.FetchItem{imap.FetchEnvelope, imap.FetchInternalDate}, ch); err != nil {
+			return nil, fmt.Errorf("fetching from %s: %w", mbox, err)
+		}
+		for msg := range ch {
+			date := msg.InternalDate
+			if msg.Envelope == nil {
+				continue
+			}
+			if !msg.Envelope.Date.IsZero() {
+				date = msg.Envelope.Date
+			}
+			msgs = append(msgs, ContextItem{Kind: "email", Title: msg.Envelope.Subject, Time: date, ID: msg.Envelope.MessageId})
+		}
+	}
+
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].Time.After(msgs[j].Time) })
+	if len(msgs) > p.maxResults {
+		msgs = msgs[:p.maxResults]
+	}
+	return msgs, nil
+}
+
+// buildIMAPCriteria matches messages from, to or cc'ing any of addrs.
+func buildIMAPCriteria(addrs []string) *imap.SearchCriteria {
+	var conds []*imap.SearchCriteria
+	for _, addr := range addrs {
+		for _, header := range []string{"From", "To", "Cc"} {
+			c := imap.NewSearchCriteria()
+			c.Header.Add(header, addr)
+			conds = append(conds, c)
+		}
+	}
+	criteria := conds[0]
+	for _, c := range conds[1:] {
+		criteria = &imap.SearchCriteria{Or: [][2]*imap.SearchCriteria{{criteria, c}}}
+	}
+	return criteria
+}
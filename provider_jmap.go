@@ -2,17 +2,26 @@ package main
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"git.sr.ht/~rockorager/go-jmap"
+	"git.sr.ht/~rockorager/go-jmap/core"
 	"git.sr.ht/~rockorager/go-jmap/mail"
 	"git.sr.ht/~rockorager/go-jmap/mail/email"
+	"git.sr.ht/~rockorager/go-jmap/mail/emailsubmission"
+	"git.sr.ht/~rockorager/go-jmap/mail/identity"
 	"github.com/emersion/go-vcard"
+	"github.com/emersion/go-webdav/carddav"
 )
 
 type jmapProvider struct {
 	client    *jmap.Client
 	accountID jmap.ID
 	maxResults int
+	// window limits context to mail received this recently; zero means all.
+	window      time.Duration
+	urlTemplate string
 }
 
 func newJMAPProvider(svc ServiceConfig) (*jmapProvider, error) {
@@ -35,60 +44,258 @@ func newJMAPProvider(svc ServiceConfig) (*jmapProvider, error) {
 		maxResults = 3
 	}
 
+	// Validated by loadConfig.
+	window, _ := parseDuration(svc.Window)
+
 	return &jmapProvider{
-		client:     client,
-		accountID:  accountID,
-		maxResults: maxResults,
+		client:      client,
+		accountID:   accountID,
+		maxResults:  maxResults,
+		window:      window,
+		urlTemplate: svc.URLTemplate,
 	}, nil
 }
 
-func (p *jmapProvider) Name() string { return "Recent emails" }
+// jmapBatchSize is how many contacts one request looks up when the server
+// doesn't advertise maxCallsInRequest. Each contact takes two calls.
+const jmapBatchSize = 16
 
-func (p *jmapProvider) GetContext(card vcard.Card) ([]string, error) {
-	addrs := extractEmails(card)
-	if len(addrs) == 0 {
-		return nil, nil
+func (p *jmapProvider) batchSize() int {
+	if c, ok := p.client.Session.Capabilities[jmap.CoreURI].(*core.Core); ok && c.MaxCallsInRequest >= 2 {
+		return int(c.MaxCallsInRequest / 2)
 	}
+	return jmapBatchSize
+}
+
+func (p *jmapProvider) GetContext(obj carddav.AddressObject) ([]ContextItem, error) {
+	items, errs := p.getContextBatch([]carddav.AddressObject{obj})
+	return items[0], errs[0]
+}
 
-	filter := buildEmailFilter(addrs)
+// getContextBatch looks up recent threads for several contacts in a single
+// request: an Email/query and Email/get pair per contact. Each thread is
+// represented by its latest message.
+func (p *jmapProvider) getContextBatch(objs []carddav.AddressObject) ([][]ContextItem, []error) {
+	items := make([][]ContextItem, len(objs))
+	errs := make([]error, len(objs))
+	contactAddrs := make([]map[string]bool, len(objs))
+
+	var after *time.Time
+	if p.window > 0 {
+		t := time.Now().UTC().Add(-p.window)
+		after = &t
+	}
 
 	req := &jmap.Request{}
-	queryID := req.Invoke(&email.Query{
-		Account: p.accountID,
-		Filter:  filter,
-		Sort: []*email.SortComparator{
-			{Property: "receivedAt", IsAscending: false},
-		},
-		Limit: uint64(p.maxResults),
-	})
-	req.Invoke(&email.Get{
-		Account:    p.accountID,
-		Properties: []string{"subject", "receivedAt"},
-		ReferenceIDs: &jmap.ResultReference{
-			ResultOf: queryID,
-			Name:     "Email/query",
-			Path:     "/ids",
-		},
-	})
+	owner := make(map[string]int) // call ID -> index into objs
+	for i, obj := range objs {
+		addrs := extractEmails(obj.Card)
+		if len(addrs) == 0 {
+			continue
+		}
+		contactAddrs[i] = make(map[string]bool, len(addrs))
+		for _, a := range addrs {
+			contactAddrs[i][strings.ToLower(a)] = true
+		}
+		var filter email.Filter = buildEmailFilter(addrs)
+		if after != nil {
+			filter = &email.FilterOperator{
+				Operator:   jmap.OperatorAND,
+				Conditions: []email.Filter{filter, &email.FilterCondition{After: after}},
+			}
+		}
+		queryID := req.Invoke(&email.Query{
+			Account: p.accountID,
+			Filter:  filter,
+			Sort: []*email.SortComparator{
+				{Property: "receivedAt", IsAscending: false},
+			},
+			CollapseThreads: true,
+			Limit:           uint64(p.maxResults),
+		})
+		getID := req.Invoke(&email.Get{
+			Account:    p.accountID,
+			Properties: []string{"subject", "receivedAt", "from", "to", "preview", "threadId", "keywords"},
+			ReferenceIDs: &jmap.ResultReference{
+				ResultOf: queryID,
+				Name:     "Email/query",
+				Path:     "/ids",
+			},
+		})
+		owner[queryID], owner[getID] = i, i
+	}
+	if len(req.Calls) == 0 {
+		return items, errs
+	}
 
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("querying emails: %w", err)
+		err = fmt.Errorf("querying emails: %w", err)
+		for i := range errs {
+			errs[i] = err
+		}
+		return items, errs
+	}
+
+	for _, inv := range resp.Responses {
+		i, ok := owner[inv.CallID]
+		if !ok {
+			continue
+		}
+		switch r := inv.Args.(type) {
+		case *email.GetResponse:
+			for _, msg := range r.List {
+				items[i] = append(items[i], p.emailItem(msg, contactAddrs[i]))
+			}
+		case *jmap.MethodError:
+			if errs[i] == nil {
+				errs[i] = fmt.Errorf("querying emails: %w", r)
+			}
+		}
+	}
+	return items, errs
+}
+
+// emailItem turns the latest message of a thread into a context item. The
+// thread is awaiting your reply if the contact sent that message and it
+// hasn't been answered.
+func (p *jmapProvider) emailItem(msg *email.Email, contact map[string]bool) ContextItem {
+	item := ContextItem{
+		Kind:     "email",
+		Title:    msg.Subject,
+		ID:       string(msg.ID),
+		ThreadID: string(msg.ThreadID),
+		Snippet:  strings.TrimSpace(msg.Preview),
+	}
+	if msg.ReceivedAt != nil {
+		item.Time = *msg.ReceivedAt
+	}
+	if len(msg.From) > 0 {
+		from := msg.From[0]
+		item.From = from.Name
+		if item.From == "" {
+			item.From = from.Email
+		}
+		item.AwaitingReply = contact[strings.ToLower(from.Email)] && !msg.Keywords["$answered"] && !msg.Keywords["$draft"]
+	}
+	if p.urlTemplate != "" {
+		item.URL = strings.NewReplacer("{id}", string(msg.ID), "{thread_id}", string(msg.ThreadID)).Replace(p.urlTemplate)
+	}
+	return item
+}
+
+// mailMessage is a message considered by 'frm sync-mail'.
+type mailMessage struct {
+	ID      string    `json:"id"` // Message-ID header, or a provider-specific ID if missing
+	From    string    `json:"from"`
+	To      []string  `json:"to,omitempty"` // To and Cc
+	Subject string    `json:"subject"`
+	Time    time.Time `json:"time"`
+}
+
+// jmapPageSize is how many messages sync-mail fetches per request.
+const jmapPageSize = 100
+
+// ownAddresses returns the addresses the account sends from: its identities,
+// plus the session username when it is an email address.
+func (p *jmapProvider) ownAddresses() ([]string, error) {
+	var addrs []string
+	if u := p.client.Session.Username; strings.Contains(u, "@") {
+		addrs = append(addrs, strings.ToLower(u))
+	}
+	if _, ok := p.client.Session.Capabilities[emailsubmission.URI]; !ok {
+		return addrs, nil
+	}
+	accountID, ok := p.client.Session.PrimaryAccounts[emailsubmission.URI]
+	if !ok {
+		accountID = p.accountID
 	}
 
-	var lines []string
+	req := &jmap.Request{}
+	req.Invoke(&identity.Get{Account: accountID})
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching identities: %w", err)
+	}
 	for _, inv := range resp.Responses {
-		if getResp, ok := inv.Args.(*email.GetResponse); ok {
-			for _, msg := range getResp.List {
-				date := ""
-				if msg.ReceivedAt != nil {
-					date = msg.ReceivedAt.Format("2006-01-02")
+		switch r := inv.Args.(type) {
+		case *identity.GetResponse:
+			for _, id := range r.List {
+				addrs = append(addrs, strings.ToLower(id.Email))
+			}
+		case *jmap.MethodError:
+			return nil, fmt.Errorf("fetching identities: %w", r)
+		}
+	}
+	return addrs, nil
+}
+
+// messagesSince returns all messages received at or after since, oldest
+// first.
+func (p *jmapProvider) messagesSince(since time.Time) ([]mailMessage, error) {
+	var msgs []mailMessage
+	for position := int64(0); ; {
+		req := &jmap.Request{}
+		queryID := req.Invoke(&email.Query{
+			Account: p.accountID,
+			Filter:  &email.FilterCondition{After: &since},
+			Sort: []*email.SortComparator{
+				{Property: "receivedAt", IsAscending: true},
+			},
+			Position: position,
+			Limit:    jmapPageSize,
+		})
+		req.Invoke(&email.Get{
+			Account:    p.accountID,
+			Properties: []string{"messageId", "from", "to", "cc", "subject", "receivedAt"},
+			ReferenceIDs: &jmap.ResultReference{
+				ResultOf: queryID,
+				Name:     "Email/query",
+				Path:     "/ids",
+			},
+		})
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("querying emails: %w", err)
+		}
+		page := 0
+		for _, inv := range resp.Responses {
+			switch r := inv.Args.(type) {
+			case *email.QueryResponse:
+				page = len(r.IDs)
+			case *email.GetResponse:
+				for _, msg := range r.List {
+					msgs = append(msgs, jmapMessage(msg))
 				}
-				lines = append(lines, fmt.Sprintf("  %s (%s)", msg.Subject, date))
+			case *jmap.MethodError:
+				return nil, fmt.Errorf("querying emails: %w", r)
 			}
 		}
+		if page < jmapPageSize {
+			return msgs, nil
+		}
+		position += int64(page)
+	}
+}
+
+func jmapMessage(msg *email.Email) mailMessage {
+	m := mailMessage{ID: "jmap:" + string(msg.ID), Subject: msg.Subject}
+	if len(msg.MessageID) > 0 {
+		m.ID = msg.MessageID[0]
+	}
+	if len(msg.From) > 0 {
+		m.From = strings.ToLower(msg.From[0].Email)
+	}
+	for _, list := range [][]*mail.Address{msg.To, msg.CC} {
+		for _, a := range list {
+			m.To = append(m.To, strings.ToLower(a.Email))
+		}
+	}
+	if msg.ReceivedAt != nil {
+		m.Time = msg.ReceivedAt.UTC()
 	}
-	return lines, nil
+	return m
 }
 
 func extractEmails(card vcard.Card) []string {
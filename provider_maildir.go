@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-webdav/carddav"
+)
+
+// mailIndex is the on-disk header index of a local mail archive, so repeat
+// lookups only parse files that are new or changed since the last run.
+type mailIndex struct {
+	path string
+
+	Files map[string]indexedFile `json:"files"`
+}
+
+// indexedFile is one maildir message or mbox file and the headers read
+// from it.
+type indexedFile struct {
+	Size     int64         `json:"size"`
+	ModTime  time.Time     `json:"mod_time"`
+	Messages []mailMessage `json:"messages"`
+}
+
+// mailIndexPath returns the index file for a service, keyed by type and path.
+func mailIndexPath(svc ServiceConfig) string {
+	sum := sha256.Sum256([]byte(svc.Type + "\x00" + svc.Path))
+	return filepath.Join(cacheDir(), svc.Type+"-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadMailIndex reads the index for a service. A missing or corrupt index
+// yields an empty one; it is rebuilt by the next refresh.
+func loadMailIndex(svc ServiceConfig) *mailIndex {
+	idx := &mailIndex{path: mailIndexPath(svc)}
+	if data, err := os.ReadFile(idx.path); err == nil {
+		if err := json.Unmarshal(data, idx); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: ignoring corrupt mail index %s: %v\n", idx.path, err)
+		}
+	}
+	if idx.Files == nil {
+		idx.Files = make(map[string]indexedFile)
+	}
+	return idx
+}
+
+func (idx *mailIndex) save() error {
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0o755); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("marshaling mail index: %w", err)
+	}
+	return writeFileAtomic(idx.path, data)
+}
+
+// refresh brings the index up to date with the files under root: files whose
+// size or modification time changed are re-read, and vanished ones dropped.
+// A file that can't be read (say, moved by a mail client mid-scan) is skipped
+// with a warning and retried next time. It reports whether anything changed.
+func (idx *mailIndex) refresh(files map[string]fs.FileInfo, parse func(path string) ([]mailMessage, error)) bool {
+	changed := false
+	for path := range idx.Files {
+		if _, ok := files[path]; !ok {
+			delete(idx.Files, path)
+			changed = true
+		}
+	}
+	for path, info := range files {
+		if f, ok := idx.Files[path]; ok && f.Size == info.Size() && f.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		msgs, err := parse(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", path, err)
+			continue
+		}
+		idx.Files[path] = indexedFile{Size: info.Size(), ModTime: info.ModTime(), Messages: msgs}
+		changed = true
+	}
+	return changed
+}
+
+// maildirFiles lists the messages in every maildir under root: the files in
+// any cur/ or new/ directory. Only a failure to read root itself is an error;
+// anything below it that can't be read is skipped with a warning.
+func maildirFiles(root string) (map[string]fs.FileInfo, error) {
+	files := make(map[string]fs.FileInfo)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", path, err)
+			return nil
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		if dir := filepath.Base(filepath.Dir(path)); dir != "cur" && dir != "new" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", path, err)
+			return nil
+		}
+		files[path] = info
+		return nil
+	})
+	return files, err
+}
+
+// mboxFiles lists root if it is a file, or the files directly inside it.
+func mboxFiles(root string) (map[string]fs.FileInfo, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]fs.FileInfo)
+	if !info.IsDir() {
+		files[root] = info
+		return files, nil
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", filepath.Join(root, e.Name()), err)
+			continue
+		}
+		files[filepath.Join(root, e.Name())] = info
+	}
+	return files, nil
+}
+
+// parseMaildirMessage reads the headers of a single-message file.
+func parseMaildirMessage(path string) ([]mailMessage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	msg, ok := parseMailHeaders(bufio.NewReader(f))
+	if !ok {
+		return nil, nil
+	}
+	if msg.ID == "" {
+		msg.ID = "maildir:" + strings.SplitN(filepath.Base(path), ":", 2)[0]
+	}
+	return []mailMessage{msg}, nil
+}
+
+// parseMbox reads the headers of every message in an mbox file. Messages
+// start at lines beginning "From " at the top of the file or after a blank
+// line.
+func parseMbox(path string) ([]mailMessage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var msgs []mailMessage
+	r := bufio.NewReader(f)
+	var header bytes.Buffer
+	inHeader, prevBlank := false, true
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			trimmed := bytes.TrimRight(line, "\r\n")
+			switch {
+			case prevBlank && bytes.HasPrefix(line, []byte("From ")):
+				inHeader = true
+				header.Reset()
+			case inHeader && len(trimmed) == 0:
+				inHeader = false
+				if msg, ok := parseMailHeaders(bufio.NewReader(io.MultiReader(&header, strings.NewReader("\r\n")))); ok {
+					if msg.ID == "" {
+						msg.ID = fmt.Sprintf("mbox:%s:%d", filepath.Base(path), len(msgs))
+					}
+					msgs = append(msgs, msg)
+				}
+			case inHeader:
+				header.Write(line)
+			}
+			prevBlank = len(trimmed) == 0
+		}
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+var headerDecoder = mime.WordDecoder{}
+
+// parseMailHeaders reads a message header block, tolerating the malformed
+// addresses real archives are full of.
+func parseMailHeaders(r *bufio.Reader) (mailMessage, bool) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return mailMessage{}, false
+	}
+	h := m.Header
+	msg := mailMessage{
+		ID:   strings.TrimSpace(h.Get("Message-Id")),
+		From: firstAddress(h.Get("From")),
+	}
+	if s, err := headerDecoder.DecodeHeader(h.Get("Subject")); err == nil {
+		msg.Subject = s
+	} else {
+		msg.Subject = h.Get("Subject")
+	}
+	for _, field := range []string{"To", "Cc"} {
+		msg.To = append(msg.To, addressList(h.Get(field))...)
+	}
+	if t, err := mail.ParseDate(h.Get("Date")); err == nil {
+		msg.Time = t.UTC()
+	}
+	return msg, true
+}
+
+func firstAddress(v string) string {
+	if addrs := addressList(v); len(addrs) > 0 {
+		return addrs[0]
+	}
+	return ""
+}
+
+// addressList returns the lowercased addresses in a header value, falling
+// back to parsing each comma-separated part on its own.
+func addressList(v string) []string {
+	if strings.TrimSpace(v) == "" {
+		return nil
+	}
+	var out []string
+	if list, err := mail.ParseAddressList(v); err == nil {
+		for _, a := range list {
+			out = append(out, strings.ToLower(a.Address))
+		}
+		return out
+	}
+	for _, part := range strings.Split(v, ",") {
+		if a, err := mail.ParseAddress(part); err == nil {
+			out = append(out, strings.ToLower(a.Address))
+		}
+	}
+	return out
+}
+
+// localMailProvider serves context from a local Maildir tree or mbox files.
+type localMailProvider struct {
+	// byAddr maps each address to the messages it sent or received.
+	byAddr     map[string][]*mailMessage
+	maxResults int
+}
+
+func newLocalMailProvider(svc ServiceConfig) (*localMailProvider, error) {
+	root := expandHome(svc.Path)
+	list, parse := maildirFiles, parseMaildirMessage
+	if svc.Type == "mbox" {
+		list, parse = mboxFiles, parseMbox
+	}
+	files, err := list(root)
+	if err != nil {
+		return nil, fmt.Errorf("scanning %s: %w", root, err)
+	}
+
+	idx := loadMailIndex(svc)
+	if idx.refresh(files, parse) {
+		if err := idx.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	maxResults := svc.MaxResults
+	if maxResults <= 0 {
+		maxResults = 3
+	}
+	p := &localMailProvider{byAddr: make(map[string][]*mailMessage), maxResults: maxResults}
+	for _, f := range idx.Files {
+		for i := range f.Messages {
+			msg := &f.Messages[i]
+			for _, addr := range append([]string{msg.From}, msg.To...) {
+				p.byAddr[addr] = append(p.byAddr[addr], msg)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *localMailProvider) GetContext(obj carddav.AddressObject) ([]ContextItem, error) {
+	seen := make(map[*mailMessage]bool)
+	var msgs []*mailMessage
+	for _, addr := range cardEmails(obj.Card) {
+		for _, msg := range p.byAddr[addr] {
+			if !seen[msg] {
+				seen[msg] = true
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].Time.After(msgs[j].Time) })
+	if len(msgs) > p.maxResults {
+		msgs = msgs[:p.maxResults]
+	}
+	var items []ContextItem
+	for _, m := range msgs {
+		items = append(items, ContextItem{Kind: "email", Title: m.Subject, Time: m.Time, ID: m.ID})
+	}
+	return items, nil
+}
+
+// expandHome expands a leading "~/" to the user's home directory.
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
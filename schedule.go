@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule is a fixed contact schedule from an RFC 5545 RRULE, such as
+// FREQ=WEEKLY;BYDAY=SU. Occurrences are whole days in local time, counted
+// from start (the day tracking began).
+//
+// FREQ, INTERVAL, BYDAY, BYMONTHDAY, BYMONTH, UNTIL and WKST are supported.
+type schedule struct {
+	Rule       string
+	start      time.Time
+	freq       string
+	interval   int
+	byDay      []scheduleDay
+	byMonthDay []int
+	byMonth    []time.Month
+	until      time.Time
+	wkst       time.Weekday
+}
+
+// scheduleDay is a BYDAY entry: a weekday, optionally the nth of its month
+// or year (negative counts from the end).
+type scheduleDay struct {
+	n       int
+	weekday time.Weekday
+}
+
+// scheduleSearchDays bounds how far to look for an occurrence, enough for a
+// rule like February 29th.
+const scheduleSearchDays = 366*8 + 2
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// parseSchedule parses an RRULE. start is the first day it applies from.
+func parseSchedule(rule string, start time.Time) (*schedule, error) {
+	rule = strings.ToUpper(strings.TrimSpace(rule))
+	rule = strings.TrimPrefix(rule, "RRULE:")
+	s := &schedule{Rule: rule, start: dateOnly(start), interval: 1, wkst: time.Monday}
+	for _, part := range strings.Split(rule, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid RRULE %q: expected KEY=VALUE, got %q", rule, part)
+		}
+		var err error
+		switch key {
+		case "FREQ":
+			switch value {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				s.freq = value
+			default:
+				err = fmt.Errorf("FREQ must be DAILY, WEEKLY, MONTHLY, or YEARLY")
+			}
+		case "INTERVAL":
+			s.interval, err = strconv.Atoi(value)
+			if err == nil && s.interval < 1 {
+				err = fmt.Errorf("INTERVAL must be at least 1")
+			}
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				day, ok := rruleWeekdays[v[max(len(v)-2, 0):]]
+				if !ok {
+					err = fmt.Errorf("invalid BYDAY %q", v)
+					break
+				}
+				sd := scheduleDay{weekday: day}
+				if n := v[:len(v)-2]; n != "" {
+					if sd.n, err = strconv.Atoi(n); err != nil || sd.n == 0 {
+						err = fmt.Errorf("invalid BYDAY %q", v)
+						break
+					}
+				}
+				s.byDay = append(s.byDay, sd)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				n, convErr := strconv.Atoi(v)
+				if convErr != nil || n == 0 || n < -31 || n > 31 {
+					err = fmt.Errorf("invalid BYMONTHDAY %q", v)
+					break
+				}
+				s.byMonthDay = append(s.byMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				n, convErr := strconv.Atoi(v)
+				if convErr != nil || n < 1 || n > 12 {
+					err = fmt.Errorf("invalid BYMONTH %q", v)
+					break
+				}
+				s.byMonth = append(s.byMonth, time.Month(n))
+			}
+		case "UNTIL":
+			var t time.Time
+			if t, err = time.Parse("20060102", value[:min(len(value), 8)]); err != nil {
+				err = fmt.Errorf("invalid UNTIL %q: use YYYYMMDD", value)
+			}
+			s.until = t
+		case "WKST":
+			day, ok := rruleWeekdays[value]
+			if !ok {
+				err = fmt.Errorf("invalid WKST %q", value)
+			}
+			s.wkst = day
+		default:
+			err = fmt.Errorf("%s isn't supported", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRULE %q: %w", rule, err)
+		}
+	}
+	if s.freq == "" {
+		return nil, fmt.Errorf("invalid RRULE %q: FREQ is required", rule)
+	}
+	for _, d := range s.byDay {
+		if d.n != 0 && (s.freq == "DAILY" || s.freq == "WEEKLY") {
+			return nil, fmt.Errorf("invalid RRULE %q: numbered BYDAY needs FREQ=MONTHLY or YEARLY", rule)
+		}
+	}
+	return s, nil
+}
+
+// occursOn reports whether day, as returned by dateOnly, is an occurrence.
+func (s *schedule) occursOn(day time.Time) bool {
+	if day.Before(s.start) || (!s.until.IsZero() && day.After(s.until)) {
+		return false
+	}
+	if len(s.byMonth) > 0 && !slices.Contains(s.byMonth, day.Month()) {
+		return false
+	}
+	if len(s.byMonthDay) > 0 && !s.matchMonthDay(day) {
+		return false
+	}
+	if len(s.byDay) > 0 && !s.matchDay(day) {
+		return false
+	}
+
+	// Without BY* rules, occurrences fall on the start's weekday, day of
+	// the month, or date.
+	noDays := len(s.byDay) == 0 && len(s.byMonthDay) == 0
+	switch {
+	case s.freq == "WEEKLY" && noDays && day.Weekday() != s.start.Weekday():
+		return false
+	case s.freq == "MONTHLY" && noDays && day.Day() != s.start.Day():
+		return false
+	case s.freq == "YEARLY" && noDays && day.Day() != s.start.Day():
+		return false
+	case s.freq == "YEARLY" && noDays && len(s.byMonth) == 0 && day.Month() != s.start.Month():
+		return false
+	}
+
+	if s.interval == 1 {
+		return true
+	}
+	var periods int
+	switch s.freq {
+	case "DAILY":
+		periods = daysBetween(s.start, day)
+	case "WEEKLY":
+		periods = daysBetween(s.weekStart(s.start), s.weekStart(day)) / 7
+	case "MONTHLY":
+		periods = (day.Year()-s.start.Year())*12 + int(day.Month()-s.start.Month())
+	case "YEARLY":
+		periods = day.Year() - s.start.Year()
+	}
+	return periods%s.interval == 0
+}
+
+func (s *schedule) matchMonthDay(day time.Time) bool {
+	last := daysIn(day.Year(), day.Month())
+	for _, n := range s.byMonthDay {
+		if n == day.Day() || (n < 0 && last+n+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *schedule) matchDay(day time.Time) bool {
+	for _, d := range s.byDay {
+		if d.weekday != day.Weekday() {
+			continue
+		}
+		if d.n == 0 {
+			return true
+		}
+		// Numbered days count within the month, or within the year for a
+		// yearly rule without BYMONTH.
+		pos, total := day.Day(), daysIn(day.Year(), day.Month())
+		if s.freq == "YEARLY" && len(s.byMonth) == 0 {
+			pos, total = day.YearDay(), time.Date(day.Year(), 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+		}
+		if d.n > 0 && (pos-1)/7+1 == d.n {
+			return true
+		}
+		if d.n < 0 && (total-pos)/7+1 == -d.n {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *schedule) weekStart(day time.Time) time.Time {
+	return day.AddDate(0, 0, -((int(day.Weekday()) - int(s.wkst) + 7) % 7))
+}
+
+// previous returns the last occurrence on or before day.
+func (s *schedule) previous(day time.Time) (time.Time, bool) {
+	day = dateOnly(day)
+	for range scheduleSearchDays {
+		if day.Before(s.start) {
+			break
+		}
+		if s.occursOn(day) {
+			return day, true
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+	return time.Time{}, false
+}
+
+// next returns the first occurrence after day.
+func (s *schedule) next(day time.Time) (time.Time, bool) {
+	day = dateOnly(day)
+	for range scheduleSearchDays {
+		day = day.AddDate(0, 0, 1)
+		if !s.until.IsZero() && day.After(s.until) {
+			break
+		}
+		if s.occursOn(day) {
+			return day, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// nominal is the rule's rough period, for comparing it with frequencies.
+func (s *schedule) nominal() time.Duration {
+	days := map[string]int{"DAILY": 1, "WEEKLY": 7, "MONTHLY": 30, "YEARLY": 365}[s.freq]
+	return time.Duration(days*s.interval) * 24 * time.Hour
+}
+
+var (
+	rruleUnits    = map[string]string{"DAILY": "day", "WEEKLY": "week", "MONTHLY": "month", "YEARLY": "year"}
+	rruleOrdinals = map[int]string{1: "first", 2: "second", 3: "third", 4: "fourth", 5: "fifth", -1: "last", -2: "second to last"}
+)
+
+// String describes the schedule in words, e.g. "weekly on Sunday" or
+// "monthly on the first Tuesday".
+func (s *schedule) String() string {
+	unit := rruleUnits[s.freq]
+	desc := strings.ToLower(s.freq)
+	if s.interval > 1 {
+		desc = fmt.Sprintf("every %d %ss", s.interval, unit)
+	}
+	var on []string
+	for _, d := range s.byDay {
+		switch name, ok := rruleOrdinals[d.n]; {
+		case d.n == 0:
+			on = append(on, d.weekday.String())
+		case ok:
+			on = append(on, "the "+name+" "+d.weekday.String())
+		default:
+			on = append(on, fmt.Sprintf("%s #%d", d.weekday, d.n))
+		}
+	}
+	for _, n := range s.byMonthDay {
+		if n < 0 {
+			on = append(on, fmt.Sprintf("day %d from the end", -n))
+		} else {
+			on = append(on, fmt.Sprintf("day %d", n))
+		}
+	}
+	if len(on) > 0 {
+		desc += " on " + strings.Join(on, ", ")
+	}
+	if len(s.byMonth) > 0 {
+		var months []string
+		for _, m := range s.byMonth {
+			months = append(months, m.String())
+		}
+		desc += " in " + strings.Join(months, ", ")
+	}
+	if !s.until.IsZero() {
+		desc += " until " + s.until.Format("2006-01-02")
+	}
+	return desc
+}
+
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Round(24*time.Hour).Hours() / 24)
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -13,29 +13,17 @@ const fieldFrequency = "X-FRM-FREQUENCY"
 const fieldIgnore = "X-FRM-IGNORE"
 const fieldGroup = "X-FRM-GROUP"
 const fieldSnoozeUntil = "X-FRM-SNOOZE-UNTIL"
+const fieldSchedule = "X-FRM-SCHEDULE"
 
-// parseDuration parses a simple duration string like "2w", "1m", "3d".
+// parseDuration parses a length written like a frequency ("7d", "1m",
+// "quarterly") into a nominal duration, for windows such as --within where
+// calendar precision doesn't matter. Use parseFrequency for schedules.
 func parseDuration(s string) (time.Duration, error) {
-	s = strings.TrimSpace(s)
-	if len(s) < 2 {
-		return 0, fmt.Errorf("invalid duration %q: too short", s)
-	}
-	suffix := s[len(s)-1]
-	numStr := s[:len(s)-1]
-	n, err := strconv.Atoi(numStr)
+	f, err := parseFrequency(s)
 	if err != nil {
-		return 0, fmt.Errorf("invalid duration %q: %w", s, err)
-	}
-	switch suffix {
-	case 'd':
-		return time.Duration(n) * 24 * time.Hour, nil
-	case 'w':
-		return time.Duration(n) * 7 * 24 * time.Hour, nil
-	case 'm':
-		return time.Duration(n) * 30 * 24 * time.Hour, nil
-	default:
-		return 0, fmt.Errorf("invalid duration %q: unknown suffix %c (use d, w, or m)", s, suffix)
+		return 0, err
 	}
+	return f.nominal(), nil
 }
 
 // getFrequency reads X-FRM-FREQUENCY from a vCard.
@@ -53,6 +41,35 @@ func removeFrequency(card vcard.Card) {
 	delete(card, fieldFrequency)
 }
 
+// getSchedule reads X-FRM-SCHEDULE from a vCard: an RRULE and, from its
+// DTSTART parameter, the day the schedule began (zero if absent).
+func getSchedule(card vcard.Card) (string, time.Time) {
+	f := card.Preferred(fieldSchedule)
+	if f == nil {
+		return "", time.Time{}
+	}
+	start, _ := time.Parse("20060102", f.Params.Get("DTSTART"))
+	return f.Value, start
+}
+
+// setSchedule sets X-FRM-SCHEDULE on a vCard.
+func setSchedule(card vcard.Card, rule string, start time.Time) {
+	card[fieldSchedule] = []*vcard.Field{{
+		Value:  rule,
+		Params: vcard.Params{"DTSTART": {start.Format("20060102")}},
+	}}
+}
+
+// removeSchedule removes X-FRM-SCHEDULE from a vCard.
+func removeSchedule(card vcard.Card) {
+	delete(card, fieldSchedule)
+}
+
+// isTracked reports whether a contact has a frequency or a schedule.
+func isTracked(card vcard.Card) bool {
+	return getFrequency(card) != "" || card.Preferred(fieldSchedule) != nil
+}
+
 // isIgnored checks if a vCard has X-FRM-IGNORE set to "true".
 func isIgnored(card vcard.Card) bool {
 	return card.PreferredValue(fieldIgnore) == "true"
@@ -135,9 +152,156 @@ func parseUntil(s string) (time.Time, error) {
 		return t, nil
 	}
 	// Try relative duration
-	dur, err := parseDuration(s)
+	f, err := parseFrequency(s)
 	if err != nil {
 		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or relative like 2m", s)
 	}
-	return time.Now().Add(dur), nil
+	return f.after(time.Now()), nil
+}
+
+// fieldXAnniversary is where vCard 3.0 clients keep anniversaries, since
+// ANNIVERSARY only exists in vCard 4.0.
+const fieldXAnniversary = "X-ANNIVERSARY"
+
+// cardDate is a birthday or anniversary from a vCard. Year is 0 when the
+// card leaves it out.
+type cardDate struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// parseCardDate parses a vCard date: 1985-04-12, 19850412, or a year-less
+// --04-12 or --0412. A trailing time is ignored, and 1604 (the year Apple
+// writes for year-less dates) is treated as no year.
+func parseCardDate(s string) (cardDate, bool) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, 'T'); i >= 0 {
+		s = s[:i]
+	}
+	var d cardDate
+	var md string
+	if strings.HasPrefix(s, "--") {
+		md = strings.ReplaceAll(s[2:], "-", "")
+	} else {
+		digits := strings.ReplaceAll(s, "-", "")
+		if len(digits) != 8 {
+			return cardDate{}, false
+		}
+		y, err := strconv.Atoi(digits[:4])
+		if err != nil {
+			return cardDate{}, false
+		}
+		d.Year, md = y, digits[4:]
+	}
+	if len(md) != 4 {
+		return cardDate{}, false
+	}
+	m, err1 := strconv.Atoi(md[:2])
+	day, err2 := strconv.Atoi(md[2:])
+	if err1 != nil || err2 != nil || m < 1 || m > 12 || day < 1 {
+		return cardDate{}, false
+	}
+	d.Month, d.Day = time.Month(m), day
+	if d.Year == 1604 {
+		d.Year = 0
+	}
+	// 2000 was a leap year, so Feb 29 is valid when there's no year.
+	check := d.Year
+	if check == 0 {
+		check = 2000
+	}
+	if time.Date(check, d.Month, d.Day, 0, 0, 0, 0, time.UTC).Day() != d.Day {
+		return cardDate{}, false
+	}
+	return d, true
+}
+
+// String formats d as YYYY-MM-DD, or --MM-DD without a year.
+func (d cardDate) String() string {
+	if d.Year == 0 {
+		return fmt.Sprintf("--%02d-%02d", int(d.Month), d.Day)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, int(d.Month), d.Day)
+}
+
+// next returns the first occurrence of d on or after today, which must be a
+// UTC midnight. Feb 29 falls on Feb 28 in non-leap years.
+func (d cardDate) next(today time.Time) time.Time {
+	for y := today.Year(); ; y++ {
+		day := d.Day
+		if d.Month == time.February && day == 29 && !isLeapYear(y) {
+			day = 28
+		}
+		t := time.Date(y, d.Month, day, 0, 0, 0, 0, time.UTC)
+		if !t.Before(today) {
+			return t
+		}
+	}
+}
+
+func isLeapYear(y int) bool {
+	return y%4 == 0 && (y%100 != 0 || y%400 == 0)
+}
+
+// dateOnly returns t's calendar day as a UTC midnight, so whole days can be
+// counted without DST getting in the way.
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// getBirthday reads BDAY from a vCard.
+func getBirthday(card vcard.Card) (cardDate, bool) {
+	return parseCardDate(card.PreferredValue(vcard.FieldBirthday))
+}
+
+// getAnniversary reads ANNIVERSARY, or X-ANNIVERSARY on vCard 3.0 cards.
+func getAnniversary(card vcard.Card) (cardDate, bool) {
+	if d, ok := parseCardDate(card.PreferredValue(vcard.FieldAnniversary)); ok {
+		return d, true
+	}
+	return parseCardDate(card.PreferredValue(fieldXAnniversary))
+}
+
+// cardDateField formats d for the card's vCard version. vCard 3.0 has no
+// year-less dates, so there it's written the way Apple does: year 1604,
+// flagged with X-APPLE-OMIT-YEAR. vCard 4.0 uses --MMDD.
+func cardDateField(card vcard.Card, d cardDate) *vcard.Field {
+	switch {
+	case d.Year != 0:
+		return &vcard.Field{Value: d.String()}
+	case card.Value("VERSION") == "4.0":
+		return &vcard.Field{Value: fmt.Sprintf("--%02d%02d", int(d.Month), d.Day)}
+	}
+	return &vcard.Field{
+		Value:  fmt.Sprintf("1604-%02d-%02d", int(d.Month), d.Day),
+		Params: vcard.Params{"X-APPLE-OMIT-YEAR": {"1604"}},
+	}
+}
+
+// setBirthday sets BDAY on a vCard.
+func setBirthday(card vcard.Card, d cardDate) {
+	card[vcard.FieldBirthday] = []*vcard.Field{cardDateField(card, d)}
+}
+
+// setAnniversary sets the anniversary in the field the card's vCard version
+// supports.
+func setAnniversary(card vcard.Card, d cardDate) {
+	removeAnniversary(card)
+	field := fieldXAnniversary
+	if card.Value("VERSION") == "4.0" {
+		field = vcard.FieldAnniversary
+	}
+	card[field] = []*vcard.Field{cardDateField(card, d)}
+}
+
+// removeBirthday removes BDAY from a vCard.
+func removeBirthday(card vcard.Card) {
+	delete(card, vcard.FieldBirthday)
+}
+
+// removeAnniversary removes ANNIVERSARY and X-ANNIVERSARY from a vCard.
+func removeAnniversary(card vcard.Card) {
+	delete(card, vcard.FieldAnniversary)
+	delete(card, fieldXAnniversary)
 }